@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+)
+
+const configFileName = "packwiz-install.toml"
+
+// Profile is a named set of install options in the config file.
+type Profile struct {
+	Url      string          `toml:"url"`
+	Dir      string          `toml:"dir,omitempty"`
+	Side     string          `toml:"side,omitempty"`
+	Hash     string          `toml:"hash,omitempty"`
+	Optional map[string]bool `toml:"optional,omitempty"`
+	// Hooks override the hooks of the config for this profile.
+	Hooks Hooks `toml:"hooks,omitempty"`
+	// Server is how the server command stops and starts the server.
+	Server ServerConfig `toml:"server,omitempty"`
+}
+
+type Config struct {
+	CurseApiKey string              `toml:"curseforge-api-key,omitempty"`
+	Hooks       Hooks               `toml:"hooks,omitempty"`
+	Profiles    map[string]*Profile `toml:"profiles"`
+	path        string
+}
+
+// ProfileNames returns the profile names in sorted order.
+func (c *Config) ProfileNames() []string {
+	var names = make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (c *Config) Profile(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in %s", name, c.path)
+	}
+	return p, nil
+}
+
+func parseConfig(data []byte, path string) (*Config, error) {
+	var c = &Config{path: path}
+	if err := toml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// expand environment variables and resolve dirs relative to the config
+	c.CurseApiKey = os.ExpandEnv(c.CurseApiKey)
+	base := filepath.Dir(path)
+	for name, p := range c.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("%s: empty profile %q", path, name)
+		}
+		p.Url = os.ExpandEnv(p.Url)
+		p.Hash = os.ExpandEnv(p.Hash)
+		p.Side = os.ExpandEnv(p.Side)
+		p.Dir = os.ExpandEnv(p.Dir)
+		p.Server.Rcon = os.ExpandEnv(p.Server.Rcon)
+		p.Server.RconPassword = os.ExpandEnv(p.Server.RconPassword)
+		if p.Dir == "" {
+			p.Dir = "."
+		}
+		if !filepath.IsAbs(p.Dir) {
+			p.Dir = filepath.Join(base, p.Dir)
+		}
+	}
+	return c, nil
+}
+
+// defaultConfigPath returns the config file next to the executable.
+func defaultConfigPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), configFileName)
+}
+
+// loadConfig reads the file of --config, or the default config if it exists.
+// It returns nil if no config file is given or found.
+func loadConfig(cmd *cobra.Command) (*Config, error) {
+	p := cmd.Flag("config").Value.String()
+	if p == "" {
+		p = defaultConfigPath()
+		if _, err := os.Stat(p); p == "" || err != nil {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data, p)
+}
+
+// requireConfig is loadConfig failing when there is no config file.
+func requireConfig(cmd *cobra.Command) (*Config, error) {
+	c, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("config file %s not found, specify it with --config", configFileName)
+	}
+	return c, nil
+}
+
+// curseApiKey returns the CurseForge api key from, in order of priority,
+// --cf-api-key, CF_API_KEY, the config file and the key built into the binary.
+func curseApiKey(cmd *cobra.Command, config *Config) string {
+	if key := cmd.Flag("cf-api-key").Value.String(); key != "" {
+		return key
+	}
+	if key := os.Getenv("CF_API_KEY"); key != "" {
+		return key
+	}
+	if config != nil && config.CurseApiKey != "" {
+		return config.CurseApiKey
+	}
+	return core.BuiltinCurseApiKey()
+}
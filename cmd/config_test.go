@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("PACK_HOST", "packs.example.com")
+	base := t.TempDir()
+	t.Setenv("CF_KEY", "secret")
+	t.Setenv("RCON_PASSWORD", "rcon-secret")
+	data := []byte(`
+curseforge-api-key = "${CF_KEY}"
+
+[hooks]
+post-install = "echo $PACKWIZ_INSTALL_ADDED"
+
+[profiles.survival]
+url = "https://${PACK_HOST}/survival/pack.toml"
+dir = "instances/survival"
+side = "client"
+hash = "sha256:abc"
+optional = { sodium = true, iris = false }
+hooks = { pre-install = "./stop.sh" }
+
+[profiles.survival.server]
+rcon = "localhost:25575"
+rcon-password = "${RCON_PASSWORD}"
+
+[profiles.creative]
+url = "https://$PACK_HOST/creative/pack.toml"
+`)
+
+	c, err := parseConfig(data, filepath.Join(base, configFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CurseApiKey != "secret" {
+		t.Errorf("CurseApiKey = %s", c.CurseApiKey)
+	}
+	if c.Hooks.PostInstall != "echo $PACKWIZ_INSTALL_ADDED" {
+		t.Errorf("Hooks.PostInstall = %s, want it not expanded", c.Hooks.PostInstall)
+	}
+	if got := c.ProfileNames(); !slices.Equal(got, []string{"creative", "survival"}) {
+		t.Errorf("ProfileNames() = %v", got)
+	}
+
+	p, err := c.Profile("survival")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Url != "https://packs.example.com/survival/pack.toml" {
+		t.Errorf("Url = %s", p.Url)
+	}
+	if want := filepath.Join(base, "instances", "survival"); p.Dir != want {
+		t.Errorf("Dir = %s, want %s", p.Dir, want)
+	}
+	if !p.Optional["sodium"] || p.Optional["iris"] {
+		t.Errorf("Optional = %v", p.Optional)
+	}
+	if p.Hooks.PreInstall != "./stop.sh" {
+		t.Errorf("Hooks.PreInstall = %s", p.Hooks.PreInstall)
+	}
+	if want := (ServerConfig{Rcon: "localhost:25575", RconPassword: "rcon-secret"}); p.Server != want {
+		t.Errorf("Server = %+v, want %+v", p.Server, want)
+	}
+
+	p, _ = c.Profile("creative")
+	if p.Dir != base {
+		t.Errorf("default Dir = %s, want %s", p.Dir, base)
+	}
+	if _, err := c.Profile("missing"); err == nil {
+		t.Errorf("Profile() missing error = nil")
+	}
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] OLD NEW",
+	Short: "Show changes between two versions of a modpack",
+	Long: `Show changes between two versions of a modpack.
+
+OLD and NEW are each one of:
+  URL of 'pack.toml'     https://example.com/pack.toml
+  local path             ./pack.toml or a directory containing it
+  git ref and path       HEAD~1:pack.toml (read from the git repository in the current directory)`,
+	Args: exactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := cmd.Flag("format").Value.String()
+		switch format {
+		case "text", "markdown", "json":
+		default:
+			return fmt.Errorf("invalid --format %q, must be text, markdown or json", format)
+		}
+
+		var packs [2]*core.Pack
+		for n, arg := range args {
+			u, cleanup, err := resolvePackArg(cmd.Context(), arg)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			var opts []core.RepoOptFn
+			if u.Scheme == "file" {
+				opts = append(opts, core.WithHttpClient(core.NewLocalFileClient()))
+			}
+			repo := core.NewRepository(u, "", "", opts...)
+			if err := repo.Load(cmd.Context()); err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			packs[n], err = core.NewPack(repo)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+		}
+
+		diff := core.DiffPacks(packs[0], packs[1])
+		switch format {
+		case "text":
+			fmt.Print(diff.String())
+		case "markdown":
+			fmt.Print(diff.Markdown())
+		case "json":
+			return printJson(diff)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("format", "f", "text", `Output format "text", "markdown" or "json"`)
+}
+
+// resolvePackArg returns the URL of pack.toml given as URL, local path or git
+// ref. cleanup removes the files extracted from git.
+func resolvePackArg(ctx context.Context, arg string) (u *url.URL, cleanup func(), err error) {
+	cleanup = func() {}
+	if u, err := url.ParseRequestURI(arg); err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+		return u, cleanup, nil
+	}
+
+	if _, err := os.Stat(arg); err == nil {
+		u, err := localPackUrl(arg)
+		return u, cleanup, err
+	}
+
+	ref, p, ok := strings.Cut(arg, ":")
+	if !ok || ref == "" {
+		return nil, cleanup, fmt.Errorf("%s is neither a URL, a local path nor <git ref>:<path>", arg)
+	}
+	dir, err := os.MkdirTemp("", "packwiz-install-diff-")
+	if err != nil {
+		return nil, cleanup, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := extractGitRef(ctx, ref, dir); err != nil {
+		return nil, cleanup, err
+	}
+	u, err = localPackUrl(filepath.Join(dir, filepath.FromSlash(p)))
+	return u, cleanup, err
+}
+
+func localPackUrl(p string) (*url.URL, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		p = filepath.Join(p, "pack.toml")
+	}
+	return core.FileUrl(p)
+}
+
+// extractGitRef writes the tree of ref in the current git repository to dir.
+func extractGitRef(ctx context.Context, ref string, dir string) error {
+	var stdout, stderr bytes.Buffer
+	git := exec.CommandContext(ctx, "git", "archive", "--format=tar", ref)
+	git.Stdout = &stdout
+	git.Stderr = &stderr
+	if err := git.Run(); err != nil {
+		return fmt.Errorf("git archive %s: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+
+	tr := tar.NewReader(&stdout)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(h.Name)) {
+			return fmt.Errorf("git archive %s: unsafe path %q", ref, h.Name)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(h.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			return err
+		}
+	}
+}
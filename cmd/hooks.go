@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/ookkoouu/packwiz-install/core"
+)
+
+const (
+	hookPreInstall  = "pre-install"
+	hookPostInstall = "post-install"
+	hookOnError     = "on-error"
+)
+
+// Hooks are shell commands run around an install. They are only read from
+// the local config file, never from a pack.
+type Hooks struct {
+	PreInstall  string `toml:"pre-install,omitempty"`
+	PostInstall string `toml:"post-install,omitempty"`
+	OnError     string `toml:"on-error,omitempty"`
+}
+
+// merge returns h with the hooks set in o overriding.
+func (h Hooks) merge(o Hooks) Hooks {
+	if o.PreInstall != "" {
+		h.PreInstall = o.PreInstall
+	}
+	if o.PostInstall != "" {
+		h.PostInstall = o.PostInstall
+	}
+	if o.OnError != "" {
+		h.OnError = o.OnError
+	}
+	return h
+}
+
+func (h Hooks) command(name string) string {
+	switch name {
+	case hookPreInstall:
+		return h.PreInstall
+	case hookPostInstall:
+		return h.PostInstall
+	case hookOnError:
+		return h.OnError
+	}
+	return ""
+}
+
+// hookRun is the install a hook is run for. Pack is nil when it failed to
+// load.
+type hookRun struct {
+	Profile string
+	Url     string
+	Dir     string
+	Pack    *core.Pack
+	Updates *core.Updates
+	Err     error
+}
+
+// env returns the environment variables describing r to the hook name.
+// The updates are written to a JSON file, removed by the returned func.
+func (r *hookRun) env(name string) ([]string, func(), error) {
+	var (
+		env = []string{
+			"PACKWIZ_INSTALL_HOOK=" + name,
+			"PACKWIZ_INSTALL_PROFILE=" + r.Profile,
+			"PACKWIZ_INSTALL_DIR=" + r.Dir,
+			"PACKWIZ_INSTALL_URL=" + r.Url,
+		}
+		cleanup = func() {}
+	)
+	if r.Pack != nil {
+		env = append(env,
+			"PACKWIZ_INSTALL_PACK_NAME="+r.Pack.Name,
+			"PACKWIZ_INSTALL_PACK_VERSION="+r.Pack.Version,
+		)
+	}
+	if r.Err != nil {
+		env = append(env, "PACKWIZ_INSTALL_ERROR="+r.Err.Error())
+	}
+	if r.Updates == nil {
+		return env, cleanup, nil
+	}
+
+	env = append(env,
+		"PACKWIZ_INSTALL_ADDED="+strconv.Itoa(len(r.Updates.Added)),
+		"PACKWIZ_INSTALL_UPDATED="+strconv.Itoa(len(r.Updates.Updated)),
+		"PACKWIZ_INSTALL_REMOVED="+strconv.Itoa(len(r.Updates.Removed)),
+	)
+	data, err := json.MarshalIndent(r.Updates, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.CreateTemp("", "packwiz-install-result-*.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	env = append(env, "PACKWIZ_INSTALL_RESULT="+f.Name())
+	return env, cleanup, nil
+}
+
+// run runs the hook name in the install directory, if it is set.
+func (h Hooks) run(ctx context.Context, name string, r *hookRun, quiet bool) error {
+	command := h.command(name)
+	if command == "" {
+		return nil
+	}
+	env, cleanup, err := r.env(name)
+	if err != nil {
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+	defer cleanup()
+
+	// the directory is not created yet if the pack failed to load
+	dir := r.Dir
+	if _, err := os.Stat(dir); err != nil {
+		dir = ""
+	}
+	c := shellCommand(ctx, command, dir, quiet)
+	c.Env = append(os.Environ(), env...)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+	return nil
+}
+
+// runOnError runs the on-error hook for err, also after Ctrl+C. A failing
+// hook is reported, not returned, to keep err.
+func (h Hooks) runOnError(ctx context.Context, r *hookRun, err error, quiet bool) {
+	r.Err = err
+	if herr := h.run(context.WithoutCancel(ctx), hookOnError, r, quiet); herr != nil {
+		fmt.Fprintln(os.Stderr, herr)
+	}
+}
+
+// shellCommand returns command run by the shell in dir. With quiet, its
+// output goes to stderr to keep stdout for JSON.
+func shellCommand(ctx context.Context, command string, dir string, quiet bool) *exec.Cmd {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/c", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	c.Dir = dir
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	if quiet {
+		c.Stdout = os.Stderr
+	}
+	c.Stderr = os.Stderr
+	return c
+}
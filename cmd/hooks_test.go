@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ookkoouu/packwiz-install/core"
+)
+
+func TestHooksMerge(t *testing.T) {
+	h := Hooks{PreInstall: "a", PostInstall: "b"}.merge(Hooks{PostInstall: "c", OnError: "d"})
+	if want := (Hooks{PreInstall: "a", PostInstall: "c", OnError: "d"}); h != want {
+		t.Errorf("merge() = %+v, want %+v", h, want)
+	}
+}
+
+func TestHooksRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are written for sh")
+	}
+	dir := t.TempDir()
+	h := Hooks{
+		PostInstall: `echo "$PACKWIZ_INSTALL_HOOK $PACKWIZ_INSTALL_PROFILE $PACKWIZ_INSTALL_PACK_NAME $PACKWIZ_INSTALL_ADDED" > env.txt && cp "$PACKWIZ_INSTALL_RESULT" result.json`,
+		OnError:     `echo "$PACKWIZ_INSTALL_ERROR" > error.txt; exit 3`,
+	}
+	r := &hookRun{
+		Profile: "survival",
+		Dir:     dir,
+		Pack:    &core.Pack{Name: "Pack", Url: "https://example.com/pack.toml"},
+		Updates: &core.Updates{Added: []*core.Mod{{Path: "mods/a.jar"}}},
+	}
+
+	if err := h.run(context.Background(), hookPreInstall, r, false); err != nil {
+		t.Fatalf("unset hook: %v", err)
+	}
+	if err := h.run(context.Background(), hookPostInstall, r, true); err != nil {
+		t.Fatal(err)
+	}
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(env)); got != "post-install survival Pack 1" {
+		t.Errorf("env = %q", got)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates core.Updates
+	if err := json.Unmarshal(data, &updates); err != nil {
+		t.Fatal(err)
+	}
+	if len(updates.Added) != 1 || updates.Added[0].Path != "mods/a.jar" {
+		t.Errorf("result = %s", data)
+	}
+
+	r.Err = errors.New("download failed")
+	err = h.run(context.Background(), hookOnError, r, true)
+	if err == nil || !strings.HasPrefix(err.Error(), "on-error hook:") {
+		t.Errorf("failing hook error = %v", err)
+	}
+	msg, _ := os.ReadFile(filepath.Join(dir, "error.txt"))
+	if got := strings.TrimSpace(string(msg)); got != "download failed" {
+		t.Errorf("PACKWIZ_INSTALL_ERROR = %q", got)
+	}
+}
+
+func TestHooksRunOnErrorWithoutPack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are written for sh")
+	}
+	out := filepath.Join(t.TempDir(), "out.txt")
+	h := Hooks{OnError: `echo "$PACKWIZ_INSTALL_URL $PACKWIZ_INSTALL_ERROR" > "` + out + `"`}
+	opts := newInstallOptions()
+	opts.Url = "https://example.com/pack.toml"
+	opts.Dir = filepath.Join(t.TempDir(), "missing")
+
+	h.runOnError(context.Background(), opts.hookRun(nil), errors.New("pack host is down"), true)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "https://example.com/pack.toml pack host is down" {
+		t.Errorf("on-error hook output = %q", got)
+	}
+}
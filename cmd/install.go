@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ookkoouu/packwiz-install/core"
 	"github.com/spf13/cobra"
@@ -12,63 +18,372 @@ import (
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
-	Use:     "install [flags] URL",
+	Use:     "install [flags] [URL]",
 	Aliases: []string{"i"},
 	Short:   "Install and update modpack",
-	Args:    exactArgs(1),
+	Args:    maximumArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// args
-		packUrl, err := url.ParseRequestURI(args[0])
+		opts, _, err := loadInstallOptions(cmd, args)
 		if err != nil {
-			return fmt.Errorf("install command requires URL of 'pack.toml'")
+			return err
 		}
-		// flags
-		var (
-			hformat string
-			hhash   string
-		)
-		if cmd.Flag("hash").Value.String() != "" {
-			var ok bool
-			hformat, hhash, ok = parseHashFlag(cmd.Flag("hash").Value.String())
-			if !ok {
-				return fmt.Errorf("invalid --hash format <HashFormat>:<Hash>")
+		return runInstall(cmd, opts)
+	},
+}
+
+// loadInstallOptions reads the options of an install from the profile of
+// --profile or the URL in args, and the flags. The config is nil if there is
+// no config file.
+func loadInstallOptions(cmd *cobra.Command, args []string) (*installOptions, *Config, error) {
+	var opts = newInstallOptions()
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.CurseApiKey = curseApiKey(cmd, config)
+	if config != nil {
+		opts.Hooks = config.Hooks
+	}
+
+	// profile
+	if name := cmd.Flag("profile").Value.String(); name != "" {
+		if config == nil {
+			config, err = requireConfig(cmd)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
-
-		repo := core.NewRepository(packUrl, hformat, hhash)
-		err = repo.Load(cmd.Context())
+		profile, err := config.Profile(name)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		pack, err := core.NewPack(repo)
+		opts.applyProfile(name, profile)
+	} else if err := exactArgs(1)(cmd, args); err != nil {
+		return nil, nil, err
+	}
+
+	// args
+	if len(args) == 1 {
+		opts.Url = args[0]
+	}
+	// flags
+	if err := opts.applyFlags(cmd); err != nil {
+		return nil, nil, err
+	}
+	return opts, config, nil
+}
+
+// installOptions are the settings of an install, from a profile and flags.
+type installOptions struct {
+	Profile      string
+	Url          string
+	Dir          string
+	Hash         string
+	Side         string
+	Optional     map[string]bool
+	FullCheck    bool
+	Force        bool
+	IgnoreFormat bool
+	LockTimeout  time.Duration
+	Changelog    bool
+	Json         bool
+	DryRun       bool
+	PreserveMode bool
+	CurseApiKey  string
+	ImportDir    string
+	// NoImport returns ManualDownloadError without waiting for the files
+	// to be downloaded.
+	NoImport bool
+	Hooks    Hooks
+}
+
+func newInstallOptions() *installOptions {
+	return &installOptions{
+		Dir:         ".",
+		Optional:    map[string]bool{},
+		LockTimeout: time.Minute,
+	}
+}
+
+func (o *installOptions) applyProfile(name string, p *Profile) {
+	o.Profile = name
+	o.Hooks = o.Hooks.merge(p.Hooks)
+	o.Url = p.Url
+	o.Dir = p.Dir
+	o.Hash = p.Hash
+	o.Side = p.Side
+	for name, v := range p.Optional {
+		o.Optional[name] = v
+	}
+}
+
+// applyFlags overrides options with the flags set on cmd.
+func (o *installOptions) applyFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Changed("dir") {
+		o.Dir, _ = flags.GetString("dir")
+	}
+	if flags.Changed("hash") {
+		o.Hash, _ = flags.GetString("hash")
+	}
+	if flags.Changed("side") {
+		o.Side, _ = flags.GetString("side")
+	}
+	if flags.Changed("optional") {
+		options, err := parseOptionalFlag(cmd)
 		if err != nil {
 			return err
 		}
-		inst, err := core.NewLocalInstaller(pack, cmd.Flag("dir").Value.String())
-		if err != nil {
-			return err
+		for name, v := range options {
+			o.Optional[name] = v
 		}
+	}
+	if flags.Changed("full-check") {
+		o.FullCheck, _ = flags.GetBool("full-check")
+	}
+	if flags.Changed("force") {
+		o.Force, _ = flags.GetBool("force")
+	}
+	if flags.Changed("ignore-format") {
+		o.IgnoreFormat, _ = flags.GetBool("ignore-format")
+	}
+	if flags.Changed("lock-timeout") {
+		o.LockTimeout, _ = flags.GetDuration("lock-timeout")
+	}
+	if flags.Changed("changelog") {
+		o.Changelog, _ = flags.GetBool("changelog")
+	}
+	if flags.Changed("json") {
+		o.Json, _ = flags.GetBool("json")
+	}
+	if flags.Changed("dry-run") {
+		o.DryRun, _ = flags.GetBool("dry-run")
+	}
+	if flags.Changed("preserve-mode") {
+		o.PreserveMode, _ = flags.GetBool("preserve-mode")
+	}
+	if flags.Changed("import-dir") {
+		o.ImportDir, _ = flags.GetString("import-dir")
+	}
+	return nil
+}
+
+func runInstall(cmd *cobra.Command, opts *installOptions) error {
+	inst, err := newInstaller(cmd, opts)
+	if err != nil {
+		opts.Hooks.runOnError(cmd.Context(), opts.hookRun(nil), err, opts.Json)
+		return err
+	}
+	if opts.DryRun {
+		return printPlan(cmd, inst, opts)
+	}
+	return installWithHooks(cmd, inst, opts)
+}
+
+// newInstaller loads the pack of opts and returns its installer.
+func newInstaller(cmd *cobra.Command, opts *installOptions) (*core.LocalInstaller, error) {
+	packUrl, err := url.ParseRequestURI(opts.Url)
+	if err != nil {
+		return nil, fmt.Errorf("install command requires URL of 'pack.toml'")
+	}
+	var (
+		hformat string
+		hhash   string
+	)
+	if opts.Hash != "" {
+		var ok bool
+		hformat, hhash, ok = parseHashFlag(opts.Hash)
+		if !ok {
+			return nil, fmt.Errorf("invalid --hash format <HashFormat>:<Hash>")
+		}
+	}
+	side, err := parseSideFlag(opts.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := core.NewRepository(
+		packUrl, hformat, hhash,
+		core.WithCacheDir(core.CacheDir(opts.Dir)),
+		core.WithIgnoreFormat(opts.IgnoreFormat),
+		core.WithLockTimeout(opts.LockTimeout),
+		core.WithReadOnlyCache(opts.DryRun),
+	)
+	err = repo.Load(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	pack, err := core.NewPack(repo)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := core.NewLocalInstaller(pack, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	inst.FullCheck = opts.FullCheck
+	inst.Force = opts.Force
+	inst.Side = side
+	inst.Options = opts.Optional
+	inst.LockTimeout = opts.LockTimeout
+	inst.PreserveMode = opts.PreserveMode
+	inst.CurseClient = core.NewCurseClient(opts.CurseApiKey)
 
+	if !opts.Json {
 		fmt.Println("URL:", packUrl)
 		fmt.Println("Dir:", inst.BaseDir)
+		if repo.FromCache {
+			fmt.Println("Pack metadata is unchanged, using cache.")
+		}
+	}
+	return inst, nil
+}
+
+func printPlan(cmd *cobra.Command, inst *core.LocalInstaller, opts *installOptions) error {
+	plan, err := inst.Plan(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if opts.Json {
+		return printJson(plan)
+	}
+	fmt.Println(plan.String())
+	return nil
+}
+
+// installWithHooks runs install between the pre-install and post-install
+// hooks, and the on-error hook if any of them fails.
+func installWithHooks(cmd *cobra.Command, inst *core.LocalInstaller, opts *installOptions) error {
+	run := opts.hookRun(inst)
+	err := opts.Hooks.run(cmd.Context(), hookPreInstall, run, opts.Json)
+	if err == nil {
+		run.Updates, err = install(cmd, inst, opts)
+	}
+	if err == nil {
+		err = opts.Hooks.run(cmd.Context(), hookPostInstall, run, opts.Json)
+	}
+	if err != nil {
+		opts.Hooks.runOnError(cmd.Context(), run, err, opts.Json)
+		return err
+	}
+	if !opts.Json {
+		fmt.Println("Complete.")
+	}
+	return nil
+}
+
+// hookRun returns the hook run of an install by inst, which is nil when the
+// pack failed to load.
+func (o *installOptions) hookRun(inst *core.LocalInstaller) *hookRun {
+	r := &hookRun{
+		Profile: o.Profile,
+		Url:     o.Url,
+		Dir:     o.Dir,
+	}
+	if abs, err := filepath.Abs(o.Dir); err == nil {
+		r.Dir = abs
+	}
+	if inst != nil {
+		r.Dir = inst.BaseDir
+		r.Pack = inst.Pack
+	}
+	return r
+}
 
-		updates, err := inst.Install(cmd.Context())
+// install runs inst and prints the result. The updates are returned along
+// with the error when only some files failed.
+func install(cmd *cobra.Command, inst *core.LocalInstaller, opts *installOptions) (*core.Updates, error) {
+	// on ManualDownloadError, the rest is installed
+	updates, err := inst.Install(cmd.Context())
+	if updates == nil {
+		return nil, err
+	}
+
+	if opts.Json {
+		if perr := printJson(updates); perr != nil {
+			return updates, perr
+		}
+		return updates, err
+	}
+	fmt.Println(updates.String())
+	var manualErr *core.ManualDownloadError
+	if errors.As(err, &manualErr) && !opts.NoImport && (opts.ImportDir != "" || isTerminal(os.Stdin)) {
+		err = waitImport(cmd.Context(), inst, manualErr.Mods, opts.ImportDir)
+	}
+	if err != nil {
+		return updates, err
+	}
+	if opts.Changelog && !updates.Changelog.Empty() {
+		fmt.Println(updates.Changelog.Markdown())
+	}
+	return updates, nil
+}
+
+// waitImport lists the mods to download manually and moves them into place
+// as they appear in dir, until all are imported or ctx is canceled.
+func waitImport(ctx context.Context, inst *core.LocalInstaller, mods []*core.Mod, dir string) error {
+	if dir == "" {
+		var err error
+		dir, err = core.DefaultImportDir()
 		if err != nil {
 			return err
 		}
+	}
+	fmt.Println((&core.ManualDownloadError{Mods: mods}).Error())
+	fmt.Printf("Waiting for the files in %s, press Ctrl+C to stop.\n", dir)
 
-		fmt.Println(updates.String())
-		fmt.Println("Complete.")
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for len(mods) > 0 {
+		imported, err := inst.ImportFiles(ctx, dir, mods)
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		for _, m := range imported {
+			fmt.Println("Imported:", m)
+		}
+		mods = slices.DeleteFunc(mods, func(m *core.Mod) bool { return slices.Contains(imported, m) })
+		if len(mods) == 0 {
+			break
+		}
 
-		return nil
-	},
+		select {
+		case <-ctx.Done():
+			return &core.ManualDownloadError{Mods: mods}
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
 
+	installCmd.Flags().String("profile", "", "Install the profile of this name in the config file")
 	installCmd.Flags().String("hash", "", `Hash of 'pack.toml' in the form of "<format>:<hash>" e.g. "sha256:abc012..."`)
 	installCmd.Flags().StringP("dir", "d", ".", "Directory to install modpack")
+	installCmd.Flags().String("side", "", `Install only mods for "client" or "server"`)
+	installCmd.Flags().StringToString("optional", nil, `Choose optional mods by metafile name e.g. "sodium=true,iris=false"`)
+	addInstallFlags(installCmd)
+}
+
+// addInstallFlags adds the flags shared by commands which install packs.
+func addInstallFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("full-check", false, "Rehash all installed files instead of comparing size and modification time")
+	cmd.Flags().Bool("force", false, "Install even if the directory has another pack installed")
+	cmd.Flags().Bool("preserve-mode", false, "Keep the mode of existing files when replacing them")
+	cmd.Flags().Bool("ignore-format", false, "Install even if the pack format version is not supported")
+	cmd.Flags().Duration("lock-timeout", time.Minute, "How long to wait for another install into the directory to finish")
+	cmd.Flags().Bool("changelog", false, "Show what's new in the modpack after install")
+	cmd.Flags().Bool("json", false, "Print the result as JSON")
+	cmd.Flags().Bool("dry-run", false, "Show the planned changes without making them")
+	cmd.Flags().String("cf-api-key", "", "CurseForge API key, also read from CF_API_KEY or the config file")
+	cmd.Flags().String("import-dir", "", "Directory to pick up manually downloaded files from (default is the Downloads folder)")
 }
 
 func parseHashFlag(s string) (format string, hash string, ok bool) {
@@ -89,3 +404,27 @@ func parseHashFlag(s string) (format string, hash string, ok bool) {
 
 	return format, hash, true
 }
+
+func parseSideFlag(s string) (core.Side, error) {
+	switch side := core.Side(s); side {
+	case "", core.Side_Both, core.Side_Client, core.Side_Server:
+		return side, nil
+	}
+	return "", fmt.Errorf("invalid --side %q, must be client, server or both", s)
+}
+
+func parseOptionalFlag(cmd *cobra.Command) (map[string]bool, error) {
+	flag, err := cmd.Flags().GetStringToString("optional")
+	if err != nil {
+		return nil, err
+	}
+	options := make(map[string]bool, len(flag))
+	for name, v := range flag {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --optional value for %q: %s", name, v)
+		}
+		options[name] = b
+	}
+	return options, nil
+}
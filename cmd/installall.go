@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// installAllCmd represents the install-all command
+var installAllCmd = &cobra.Command{
+	Use:   "install-all [flags]",
+	Short: "Install and update all profiles in the config file",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := requireConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, name := range config.ProfileNames() {
+			fmt.Println("Profile:", name)
+
+			var opts = newInstallOptions()
+			opts.CurseApiKey = curseApiKey(cmd, config)
+			opts.Hooks = config.Hooks
+			opts.applyProfile(name, config.Profiles[name])
+			if err := opts.applyFlags(cmd); err != nil {
+				return err
+			}
+			if err := runInstall(cmd, opts); err != nil {
+				fmt.Println("Failed:", err)
+				errs = append(errs, fmt.Errorf("profile %s: %w", name, err))
+			}
+			fmt.Println()
+		}
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(installAllCmd)
+
+	addInstallFlags(installAllCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/spf13/cobra"
+)
+
+// repairCmd represents the repair command
+var repairCmd = &cobra.Command{
+	Use:   "repair [flags]",
+	Short: "Redownload missing or corrupted files of the installed modpack",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := cmd.Flag("dir").Value.String()
+		pack, err := core.LoadInstalledPack(dir)
+		if err != nil {
+			return err
+		}
+		inst, err := core.NewLocalInstaller(pack, dir)
+		if err != nil {
+			return err
+		}
+		inst.LockTimeout, _ = cmd.Flags().GetDuration("lock-timeout")
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		inst.CurseClient = core.NewCurseClient(curseApiKey(cmd, config))
+
+		if pack.Url != "" {
+			fmt.Println("URL:", pack.Url)
+		}
+		fmt.Println("Dir:", inst.BaseDir)
+
+		result, err := inst.Repair(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(result.String())
+		fmt.Println("Complete.")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+
+	repairCmd.Flags().StringP("dir", "d", ".", "Directory the modpack is installed in")
+	repairCmd.Flags().String("cf-api-key", "", "CurseForge API key, also read from CF_API_KEY or the config file")
+	repairCmd.Flags().Duration("lock-timeout", time.Minute, "How long to wait for another install into the directory to finish")
+}
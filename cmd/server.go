@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/spf13/cobra"
+)
+
+// serverCmd represents the server command
+var serverCmd = &cobra.Command{
+	Use:   "server [flags] [URL]",
+	Short: "Install and update the server side of modpack, stopping the server while files change",
+	Args:  maximumArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, config, err := loadInstallOptions(cmd, args)
+		if err != nil {
+			return err
+		}
+		if opts.Side != "" && opts.Side != string(core.Side_Server) {
+			return fmt.Errorf("server command installs the server side, not %q", opts.Side)
+		}
+		opts.Side = string(core.Side_Server)
+		// never wait for manual downloads with the server stopped
+		opts.NoImport = true
+
+		var srv = newServerOptions()
+		if opts.Profile != "" {
+			srv.applyConfig(config.Profiles[opts.Profile].Server)
+		}
+		if err := srv.applyFlags(cmd); err != nil {
+			return err
+		}
+		return runServer(cmd, opts, srv)
+	},
+}
+
+// ServerConfig is how the server of a profile is stopped and started, with
+// commands or a stop command sent over RCON.
+type ServerConfig struct {
+	Stop         string `toml:"stop,omitempty"`
+	Start        string `toml:"start,omitempty"`
+	Rcon         string `toml:"rcon,omitempty"`
+	RconPassword string `toml:"rcon-password,omitempty"`
+}
+
+// serverOptions are the settings of the server command, from a profile and
+// flags.
+type serverOptions struct {
+	ServerConfig
+	StopTimeout time.Duration
+	Watch       time.Duration
+}
+
+func newServerOptions() *serverOptions {
+	return &serverOptions{
+		StopTimeout: 2 * time.Minute,
+	}
+}
+
+func (o *serverOptions) applyConfig(c ServerConfig) {
+	o.ServerConfig = c
+}
+
+// applyFlags overrides options with the flags set on cmd.
+func (o *serverOptions) applyFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Changed("stop-cmd") {
+		o.Stop, _ = flags.GetString("stop-cmd")
+	}
+	if flags.Changed("start-cmd") {
+		o.Start, _ = flags.GetString("start-cmd")
+	}
+	if flags.Changed("rcon") {
+		o.Rcon, _ = flags.GetString("rcon")
+	}
+	if flags.Changed("rcon-password") {
+		o.RconPassword, _ = flags.GetString("rcon-password")
+	}
+	if flags.Changed("stop-timeout") {
+		o.StopTimeout, _ = flags.GetDuration("stop-timeout")
+	}
+	if flags.Changed("watch") {
+		o.Watch, _ = flags.GetDuration("watch")
+	}
+
+	if o.Stop == "" && o.Rcon == "" {
+		return fmt.Errorf("server command requires --stop-cmd or --rcon to stop the server")
+	}
+	if o.Stop != "" && o.Rcon != "" {
+		return fmt.Errorf("--stop-cmd and --rcon cannot be used together")
+	}
+	return nil
+}
+
+// runServer updates the server once, or every Watch interval until
+// interrupted. With Watch, failed updates are reported and retried on the
+// next poll.
+func runServer(cmd *cobra.Command, opts *installOptions, srv *serverOptions) error {
+	if srv.Watch <= 0 {
+		return updateServer(cmd, opts, srv)
+	}
+
+	ticker := time.NewTicker(srv.Watch)
+	defer ticker.Stop()
+	for {
+		if err := updateServer(cmd, opts, srv); err != nil && cmd.Context().Err() == nil {
+			fmt.Fprintln(os.Stderr, "Failed:", err)
+		}
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateServer installs the pack if it changes any file. The server is
+// stopped after the downloads, only while files are replaced.
+func updateServer(cmd *cobra.Command, opts *installOptions, srv *serverOptions) error {
+	inst, err := newInstaller(cmd, opts)
+	if err != nil {
+		opts.Hooks.runOnError(cmd.Context(), opts.hookRun(nil), err, opts.Json)
+		return err
+	}
+	if opts.DryRun {
+		return printPlan(cmd, inst, opts)
+	}
+	plan, err := inst.Plan(cmd.Context())
+	if err != nil {
+		return err
+	}
+	// files to download manually would restart the server at every poll
+	if !plan.Changed() {
+		if !opts.Json {
+			fmt.Println("No changes.")
+		}
+		return nil
+	}
+
+	var stopped bool
+	inst.BeforeChange = func(ctx context.Context) error {
+		if err := srv.stop(ctx, inst.BaseDir, opts.Json); err != nil {
+			return fmt.Errorf("stop server: %w", err)
+		}
+		stopped = true
+		return nil
+	}
+	err = installWithHooks(cmd, inst, opts)
+	if !stopped {
+		return err
+	}
+	// start the server again even if the install failed after it stopped
+	if serr := srv.start(context.WithoutCancel(cmd.Context()), inst.BaseDir, opts.Json); serr != nil {
+		err = errors.Join(err, fmt.Errorf("start server: %w", serr))
+	}
+	return err
+}
+
+// stop stops the server with the stop command, or with the stop command of
+// the game over RCON, waiting until the RCON port closes.
+func (o *serverOptions) stop(ctx context.Context, dir string, quiet bool) error {
+	if o.Stop != "" {
+		logf(quiet, "Stopping server: %s\n", o.Stop)
+		return shellCommand(ctx, o.Stop, dir, quiet).Run()
+	}
+
+	rcon, err := core.DialRcon(ctx, o.Rcon, o.RconPassword)
+	if err != nil {
+		if connRefused(err) {
+			logf(quiet, "Server is not running.\n")
+			return nil
+		}
+		return err
+	}
+	logf(quiet, "Stopping server over RCON: %s\n", o.Rcon)
+	_, err = rcon.Command("stop")
+	rcon.Close()
+	// the server may close the connection before it replies
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, syscall.ECONNRESET) {
+		return err
+	}
+	return waitClosed(ctx, o.Rcon, o.StopTimeout)
+}
+
+// start runs the start command, if any. Without one, the server is left to
+// be restarted by its supervisor.
+func (o *serverOptions) start(ctx context.Context, dir string, quiet bool) error {
+	if o.Start == "" {
+		return nil
+	}
+	logf(quiet, "Starting server: %s\n", o.Start)
+	return shellCommand(ctx, o.Start, dir, quiet).Run()
+}
+
+// waitClosed waits until nothing listens on addr anymore.
+func waitClosed(ctx context.Context, addr string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+		} else if connRefused(err) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("server is still running after %s", timeout)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// connRefused reports whether err is a refused connection, meaning that the
+// server is not running. Other dial errors such as a wrong address are not.
+func connRefused(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// WSAECONNREFUSED
+	var errno syscall.Errno
+	return runtime.GOOS == "windows" && errors.As(err, &errno) && errno == 10061
+}
+
+// logf prints a progress message, to stderr with quiet to keep stdout for
+// JSON.
+func logf(quiet bool, format string, a ...any) {
+	if quiet {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+
+	serverCmd.Flags().String("profile", "", "Install the profile of this name in the config file")
+	serverCmd.Flags().String("hash", "", `Hash of 'pack.toml' in the form of "<format>:<hash>" e.g. "sha256:abc012..."`)
+	serverCmd.Flags().StringP("dir", "d", ".", "Directory to install modpack")
+	serverCmd.Flags().StringToString("optional", nil, `Choose optional mods by metafile name e.g. "sodium=true,iris=false"`)
+	addInstallFlags(serverCmd)
+	serverCmd.Flags().String("stop-cmd", "", "Command to stop the server before files change")
+	serverCmd.Flags().String("start-cmd", "", "Command to start the server after install, which must not wait for the server to exit")
+	serverCmd.Flags().String("rcon", "", `Address of the RCON of the server to send "stop" to instead of --stop-cmd e.g. "localhost:25575"`)
+	serverCmd.Flags().String("rcon-password", "", "RCON password")
+	serverCmd.Flags().Duration("stop-timeout", 2*time.Minute, "How long to wait for the server to stop over RCON")
+	serverCmd.Flags().Duration("watch", 0, `Check the pack for updates at this interval e.g. "5m", instead of once`)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestServerStopNotRunning(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	srv := newServerOptions()
+	srv.Rcon = addr
+	if err := srv.stop(context.Background(), t.TempDir(), true); err != nil {
+		t.Errorf("stop() = %v, want nil when the server is not running", err)
+	}
+
+	// a wrong address is not a stopped server
+	srv.Rcon = "server.invalid:25575"
+	if err := srv.stop(context.Background(), t.TempDir(), true); err == nil {
+		t.Errorf("stop() with unknown host = nil")
+	}
+}
+
+func TestWaitClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	addr := l.Addr().String()
+
+	if err := waitClosed(context.Background(), addr, 100*time.Millisecond); err == nil {
+		t.Errorf("waitClosed() = nil while listening")
+	}
+	time.AfterFunc(500*time.Millisecond, func() { l.Close() })
+	if err := waitClosed(context.Background(), addr, 10*time.Second); err != nil {
+		t.Errorf("waitClosed() = %v after close", err)
+	}
+}
+
+func TestUpdateServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("server commands are written for sh")
+	}
+	pack := t.TempDir()
+	index := fmt.Sprintf("hash-format = \"sha256\"\n\n[[files]]\nfile = \"config/a.txt\"\nhash = \"%x\"\n", sha256.Sum256([]byte("a")))
+	files := map[string]string{
+		"config/a.txt": "a",
+		"index.toml":   index,
+		"pack.toml":    fmt.Sprintf("name = \"test\"\npack-format = \"packwiz:1.1.0\"\n\n[index]\nfile = \"index.toml\"\nhash-format = \"sha256\"\nhash = \"%x\"\n", sha256.Sum256([]byte(index))),
+	}
+	for name, body := range files {
+		p := filepath.Join(pack, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := httptest.NewServer(http.FileServer(http.Dir(pack)))
+	defer s.Close()
+
+	dir := t.TempDir()
+	log := filepath.Join(t.TempDir(), "log.txt")
+	opts := newInstallOptions()
+	opts.Url = s.URL + "/pack.toml"
+	opts.Dir = dir
+	opts.Json = true
+	opts.NoImport = true
+	srv := newServerOptions()
+	srv.Stop = `echo stop >> "` + log + `"`
+	srv.Start = `echo start >> "` + log + `"`
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	if err := updateServer(cmd, opts, srv); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(log); string(data) != "stop\nstart\n" {
+		t.Errorf("commands of the first update = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config", "a.txt")); string(data) != "a" {
+		t.Errorf("config/a.txt = %q", data)
+	}
+
+	// no changes, the server keeps running
+	if err := os.Remove(log); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateServer(cmd, opts, srv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Errorf("stop or start command run without changes: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status [flags]",
+	Short: "Show the installed modpack",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := core.LoadState(cmd.Flag("dir").Value.String())
+		if err != nil {
+			return err
+		}
+		if state.Pack.Url == "" {
+			return fmt.Errorf("no pack is installed")
+		}
+		if isJson, _ := cmd.Flags().GetBool("json"); isJson {
+			return printJson(state)
+		}
+
+		fmt.Println("Pack:", state.Pack.Name, state.Pack.Version)
+		fmt.Println("URL:", state.Pack.Url)
+		fmt.Println("Installed:", state.InstalledAt.Local().Format(time.DateTime))
+		if state.Side != "" {
+			fmt.Println("Side:", state.Side)
+		}
+		var names []string
+		for name := range state.Options {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("Optional: %s=%t\n", name, state.Options[name])
+		}
+		fmt.Println("Files:")
+		for _, m := range state.Files {
+			fmt.Printf("  %s\n", m)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringP("dir", "d", ".", "Directory the modpack is installed in")
+	statusCmd.Flags().Bool("json", false, "Print the installed state as JSON")
+}
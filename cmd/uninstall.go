@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ookkoouu/packwiz-install/core"
+	"github.com/spf13/cobra"
+)
+
+// uninstallCmd represents the uninstall command
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall [flags]",
+	Short: "Remove all files installed by the modpack",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		u, err := core.NewUninstaller(cmd.Flag("dir").Value.String())
+		if err != nil {
+			return err
+		}
+		u.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		u.LockTimeout, _ = cmd.Flags().GetDuration("lock-timeout")
+
+		fmt.Println("Dir:", u.BaseDir)
+
+		result, err := u.Uninstall(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(result.String())
+		if u.DryRun {
+			fmt.Println("Dry run, nothing was removed.")
+		} else {
+			fmt.Println("Complete.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+
+	uninstallCmd.Flags().StringP("dir", "d", ".", "Directory the modpack is installed in")
+	uninstallCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing anything")
+	uninstallCmd.Flags().Duration("lock-timeout", time.Minute, "How long to wait for another install into the directory to finish")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -20,9 +21,32 @@ func exactArgs(n int) cobra.PositionalArgs {
 	}
 }
 
+func maximumArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) <= n {
+			return nil
+		}
+		return fmt.Errorf(
+			"%q accepts at most %d %s.\n",
+			cmd.CommandPath(),
+			n,
+			pluralize("argument", n),
+		)
+	}
+}
+
 func pluralize(word string, number int) string {
 	if number == 1 {
 		return word
 	}
 	return word + "s"
 }
+
+func printJson(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
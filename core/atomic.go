@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Modes of written files and directories, subject to umask.
+const (
+	filePerm os.FileMode = 0o644
+	execPerm os.FileMode = 0o755
+	dirPerm  os.FileMode = 0o755
+)
+
+// tempSuffix marks the temp files of writeFileAtomic, so the ones left by a
+// crash are found and removed by the next run.
+const tempSuffix = ".pw-tmp"
+
+// createTemp creates a new temp file next to p. Unlike os.CreateTemp, the
+// mode is perm subject to umask, as with os.WriteFile.
+func createTemp(p string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".")
+	for try := 0; ; try++ {
+		name := prefix + strconv.FormatUint(uint64(rand.Uint32()), 36) + tempSuffix
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// writeFileAtomic writes data to a temp file next to p, syncs it and renames
+// it over p, so p never has partial content.
+func writeFileAtomic(p string, data []byte, perm os.FileMode) error {
+	f, err := createTemp(p, perm)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, p)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// removeTempFiles removes the temp files and staging directories left in
+// dirs by interrupted writes. Missing dirs and files in place of dirs are
+// skipped.
+func removeTempFiles(dirs []string) error {
+	for _, d := range dirs {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+				continue
+			}
+			return err
+		}
+		for _, e := range entries {
+			if !strings.HasSuffix(e.Name(), tempSuffix) || !(e.Type().IsRegular() || e.IsDir()) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(d, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
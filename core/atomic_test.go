@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.jar")
+	for _, want := range []string{"first", "second"} {
+		if err := writeFileAtomic(p, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(p); string(data) != want {
+			t.Errorf("content = %q, want %q", data, want)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files = %d, want 1", len(entries))
+	}
+}
+
+func TestLocalInstaller_InstallRemovesTempFiles(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "a"})
+	dir := t.TempDir()
+
+	// left by a crash during a previous install
+	leftover := filepath.Join(dir, "mods", ".a.jar.x1"+tempSuffix)
+	if err := os.MkdirAll(filepath.Dir(leftover), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leftover, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mods", "mine.jar"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestInstaller(t, srv, dir).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "mods"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "a.jar,mine.jar" {
+		t.Errorf("mods = %s, want a.jar,mine.jar", got)
+	}
+}
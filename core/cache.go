@@ -0,0 +1,39 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+func saveJsonFile(p string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), dirPerm)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, filePerm)
+}
+
+// restoreJsonFile decodes p into v. A missing file leaves v untouched.
+func restoreJsonFile(p string, v any) error {
+	if _, err := os.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return nil
+}
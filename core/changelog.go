@@ -0,0 +1,122 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Changelog describes an install for players.
+type Changelog struct {
+	Pack       string
+	OldVersion string
+	NewVersion string
+	Date       time.Time
+	Added      []*Mod
+	Removed    []*Mod
+	Updated    []*ModChange
+}
+
+// newChangelog builds the changelog of an install from the previous state
+// and its result. Files reinstalled only because they were broken are left out.
+func newChangelog(prev *State, pack *Pack, u *Updates) *Changelog {
+	var c = &Changelog{
+		Pack:       pack.Name,
+		OldVersion: prev.Pack.Version,
+		NewVersion: pack.Version,
+		Date:       time.Now(),
+	}
+
+	installed := make(map[string]*Mod, len(prev.Files))
+	for _, m := range prev.Files {
+		installed[m.Path] = m
+	}
+	removed := make(map[string]*Mod, len(u.Removed))
+	for _, m := range u.Removed {
+		removed[diffKey(m)] = m
+	}
+
+	for _, m := range slices.Concat(u.Added, u.Updated) {
+		if o, ok := installed[m.Path]; ok && o.Hash == m.Hash {
+			continue
+		}
+		if o, ok := removed[diffKey(m)]; ok {
+			c.Updated = append(c.Updated, &ModChange{Old: o, New: m})
+			delete(removed, diffKey(m))
+			continue
+		}
+		if o, ok := installed[m.Path]; ok {
+			c.Updated = append(c.Updated, &ModChange{Old: o, New: m})
+			continue
+		}
+		c.Added = append(c.Added, m)
+	}
+	for _, m := range u.Removed {
+		if _, ok := removed[diffKey(m)]; ok {
+			c.Removed = append(c.Removed, m)
+		}
+	}
+
+	byName := func(a, b *Mod) int { return cmp.Compare(a.DisplayName(), b.DisplayName()) }
+	slices.SortFunc(c.Added, byName)
+	slices.SortFunc(c.Removed, byName)
+	slices.SortFunc(c.Updated, func(a, b *ModChange) int { return byName(a.New, b.New) })
+	return c
+}
+
+func (c *Changelog) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0 && c.OldVersion == c.NewVersion
+}
+
+func (c *Changelog) Markdown() string {
+	var s string
+	title := c.Pack
+	switch {
+	case c.OldVersion != c.NewVersion && c.OldVersion != "":
+		title += fmt.Sprintf(" %s -> %s", c.OldVersion, c.NewVersion)
+	case c.NewVersion != "":
+		title += " " + c.NewVersion
+	}
+	s += fmt.Sprintf("## %s (%s)\n\n", title, c.Date.Format(time.DateOnly))
+
+	for _, m := range c.Added {
+		s += fmt.Sprintf("- Added %s\n", m.DisplayName())
+	}
+	for _, u := range c.Updated {
+		oldFile, newFile := path.Base(u.Old.Path), path.Base(u.New.Path)
+		if oldFile != newFile {
+			s += fmt.Sprintf("- Updated %s from %s to %s\n", u.New.DisplayName(), oldFile, newFile)
+		} else {
+			s += fmt.Sprintf("- Updated %s\n", u.New.DisplayName())
+		}
+	}
+	for _, m := range c.Removed {
+		s += fmt.Sprintf("- Removed %s\n", m.DisplayName())
+	}
+	if len(c.Added)+len(c.Updated)+len(c.Removed) == 0 {
+		s += "- No file changes\n"
+	}
+	return s
+}
+
+func changelogPath(baseDir string) string {
+	return filepath.Join(CacheDir(baseDir), "CHANGELOG.md")
+}
+
+// save prepends c to the changelog file of baseDir.
+func (c *Changelog) save(baseDir string) error {
+	p := changelogPath(baseDir)
+	prev, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	data := []byte(c.Markdown())
+	if len(prev) > 0 {
+		data = append(append(data, '\n'), prev...)
+	}
+	return writeFileAtomic(p, data, filePerm)
+}
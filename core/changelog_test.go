@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewChangelog(t *testing.T) {
+	var (
+		sodiumOld = &Mod{Path: "mods/sodium-0.5.jar", Hash: "s1", Metafile: "mods/sodium.pw.toml", Name: "Sodium"}
+		sodiumNew = &Mod{Path: "mods/sodium-0.6.jar", Hash: "s2", Metafile: "mods/sodium.pw.toml", Name: "Sodium"}
+		iris      = &Mod{Path: "mods/iris.jar", Hash: "i1", Metafile: "mods/iris.pw.toml", Name: "Iris"}
+		lithium   = &Mod{Path: "mods/lithium.jar", Hash: "l1", Metafile: "mods/lithium.pw.toml", Name: "Lithium"}
+		cfgOld    = &Mod{Path: "config/a.cfg", Hash: "a1"}
+		cfgNew    = &Mod{Path: "config/a.cfg", Hash: "a2"}
+		broken    = &Mod{Path: "mods/broken.jar", Hash: "b1", Name: "Broken"}
+	)
+	prev := &State{
+		Pack:  PackIdentity{Name: "p", Version: "1.0"},
+		Files: []*Mod{sodiumOld, lithium, cfgOld, broken},
+	}
+	pack := &Pack{Name: "p", Version: "1.1"}
+	u := &Updates{
+		Added:   []*Mod{sodiumNew, iris, broken},
+		Updated: []*Mod{cfgNew},
+		Removed: []*Mod{sodiumOld, lithium},
+	}
+
+	c := newChangelog(prev, pack, u)
+	md := c.Markdown()
+	for _, want := range []string{
+		"## p 1.0 -> 1.1",
+		"- Added Iris\n",
+		"- Updated Sodium from sodium-0.5.jar to sodium-0.6.jar\n",
+		"- Updated config/a.cfg\n",
+		"- Removed Lithium\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() does not contain %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "Broken") {
+		t.Errorf("Markdown() contains repaired file:\n%s", md)
+	}
+
+	c = newChangelog(&State{Pack: pack.Identity()}, pack, &Updates{})
+	if !c.Empty() {
+		t.Errorf("Empty() = false for no changes")
+	}
+}
@@ -3,14 +3,19 @@ package core
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/carlmjohnson/requests"
 )
 
 var (
-	cf_api_key         = ""
-	cf_api_host        = "https://api.curseforge.com"
+	cf_api_key  = ""
+	cf_api_host = "https://api.curseforge.com"
+	cf_cdn_host = "https://edge.forgecdn.net"
+	// cf_files_batch is the most file ids sent in one files request.
+	cf_files_batch     = 500
 	DefaultCurseClient = NewCurseClient(getApiKey())
 )
 
@@ -18,17 +23,45 @@ type cfDownloadUrlRes struct {
 	Data string `json:"data"`
 }
 
+// CurseFile is a file of the CurseForge files endpoint. DownloadUrl is empty
+// when the author disallowed distribution through third-party apps.
+type CurseFile struct {
+	ID          int             `json:"id"`
+	ModID       int             `json:"modId"`
+	FileName    string          `json:"fileName"`
+	DownloadUrl string          `json:"downloadUrl"`
+	Hashes      []CurseFileHash `json:"hashes"`
+}
+
+// CurseFileHash is a hash of a CurseFile. Algo is 1 for sha1 and 2 for md5.
+type CurseFileHash struct {
+	Value string `json:"value"`
+	Algo  int    `json:"algo"`
+}
+
+type cfFilesReq struct {
+	FileIDs []int `json:"fileIds"`
+}
+
+type cfFilesRes struct {
+	Data []*CurseFile `json:"data"`
+}
+
 type CurseClient struct {
 	apiKey     string
 	httpClient *requests.Builder
 }
 
 func NewCurseClient(apiKey string) *CurseClient {
+	return newCurseClient(cf_api_host, apiKey)
+}
+
+func newCurseClient(host string, apiKey string) *CurseClient {
 	return &CurseClient{
 		apiKey: apiKey,
 		httpClient: defaultRequestBuilder.
 			Clone().
-			BaseURL(cf_api_host).
+			BaseURL(host).
 			Header("x-api-key", apiKey),
 	}
 }
@@ -41,18 +74,74 @@ func getApiKey() string {
 	return key
 }
 
+// BuiltinCurseApiKey returns the api key compiled into the binary, if any.
+func BuiltinCurseApiKey() string {
+	return cf_api_key
+}
+
+// HasKey reports whether c has an api key. Without one, files are fetched
+// from the CDN instead.
+func (c *CurseClient) HasKey() bool {
+	return c.apiKey != ""
+}
+
+// keyError replaces the error of a request refused for the api key with a
+// hint to fix it.
+func keyError(err error) error {
+	if requests.HasStatusErr(err, http.StatusUnauthorized, http.StatusForbidden) {
+		return fmt.Errorf("curseforge api key is rejected, check --cf-api-key or CF_API_KEY")
+	}
+	return err
+}
+
+// CurseCdnUrl returns the public CDN URL of a CurseForge file, which works
+// for most files without an api key.
+func CurseCdnUrl(fileID int, filename string) string {
+	return fmt.Sprintf("%s/files/%d/%d/%s", cf_cdn_host, fileID/1000, fileID%1000, url.PathEscape(filename))
+}
+
 func (c *CurseClient) getJson(ctx context.Context, path string, v any) error {
 	if c.apiKey == "" {
 		return fmt.Errorf("invalid curseforge api key")
 	}
 
-	err := c.httpClient.Path(path).ToJSON(&v).Fetch(context.WithoutCancel(ctx))
+	err := c.httpClient.Clone().Path(path).ToJSON(&v).Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("curseforge api: %w", err)
+	}
+	return nil
+}
+
+func (c *CurseClient) postJson(ctx context.Context, path string, body any, v any) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("invalid curseforge api key")
+	}
+
+	err := c.httpClient.Clone().Path(path).BodyJSON(body).ToJSON(&v).Fetch(ctx)
 	if err != nil {
 		return fmt.Errorf("curseforge api: %w", err)
 	}
 	return nil
 }
 
+// GetFiles resolves files by id in batches, keyed by file id. Files unknown
+// to CurseForge are missing from the result.
+func (c *CurseClient) GetFiles(ctx context.Context, fileIDs []int) (map[int]*CurseFile, error) {
+	var files = make(map[int]*CurseFile, len(fileIDs))
+	for start := 0; start < len(fileIDs); start += cf_files_batch {
+		ids := fileIDs[start:min(start+cf_files_batch, len(fileIDs))]
+		var res cfFilesRes
+		err := c.postJson(ctx, "/v1/mods/files", cfFilesReq{FileIDs: ids}, &res)
+		if err != nil {
+			return nil, keyError(err)
+		}
+		for _, f := range res.Data {
+			files[f.ID] = f
+		}
+	}
+	return files, nil
+}
+
 func (c *CurseClient) GetDownloadUrl(ctx context.Context, d *CurseforgeData) (string, error) {
 	path := fmt.Sprintf("/v1/mods/%d/files/%d/download-url", d.ProjectID, d.FileID)
 	var resUrl cfDownloadUrlRes
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCurseCdnUrl(t *testing.T) {
+	got := CurseCdnUrl(4567890, "iris-mc1.20.1 1.6.4.jar")
+	want := "https://edge.forgecdn.net/files/4567/890/iris-mc1.20.1%201.6.4.jar"
+	if got != want {
+		t.Errorf("CurseCdnUrl() = %s, want %s", got, want)
+	}
+}
+
+type testCurseServer struct {
+	*httptest.Server
+	// files maps file ids to their name and content. Ids listed in
+	// disallowed have no download URL.
+	files      map[int][2]string
+	disallowed map[int]bool
+	batches    [][]int
+	singles    int
+}
+
+func newTestCurseServer(t *testing.T, files map[int][2]string) *testCurseServer {
+	t.Helper()
+	s := &testCurseServer{files: files, disallowed: map[int]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/mods/files", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req cfFilesReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.batches = append(s.batches, req.FileIDs)
+		var res cfFilesRes
+		for _, id := range req.FileIDs {
+			f, ok := s.files[id]
+			if !ok {
+				continue
+			}
+			cf := &CurseFile{ID: id, ModID: 1, FileName: f[0]}
+			if !s.disallowed[id] {
+				cf.DownloadUrl = fmt.Sprintf("%s/dl/%d", s.URL, id)
+			}
+			res.Data = append(res.Data, cf)
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	mux.HandleFunc("GET /v1/mods/{mod}/files/{file}/download-url", func(w http.ResponseWriter, r *http.Request) {
+		s.singles++
+		json.NewEncoder(w).Encode(cfDownloadUrlRes{Data: fmt.Sprintf("%s/dl/%s", s.URL, r.PathValue("file"))})
+	})
+	mux.HandleFunc("GET /dl/{file}", func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		fmt.Sscan(r.PathValue("file"), &id)
+		fmt.Fprint(w, s.files[id][1])
+	})
+	mux.HandleFunc("GET /files/{a}/{b}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		var a, b int
+		fmt.Sscan(r.PathValue("a"), &a)
+		fmt.Sscan(r.PathValue("b"), &b)
+		f, ok := s.files[a*1000+b]
+		if !ok || f[0] != r.PathValue("name") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, f[1])
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCurseClient_GetFiles(t *testing.T) {
+	srv := newTestCurseServer(t, map[int][2]string{
+		1001: {"a.jar", "a"},
+		1002: {"b.jar", "b"},
+		1003: {"c.jar", "c"},
+	})
+	srv.disallowed[1002] = true
+	defer func(n int) { cf_files_batch = n }(cf_files_batch)
+	cf_files_batch = 2
+
+	files, err := newCurseClient(srv.URL, "key").GetFiles(context.Background(), []int{1001, 1002, 1003, 1004})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.batches) != 2 {
+		t.Errorf("requests = %d, want 2", len(srv.batches))
+	}
+	if len(files) != 3 {
+		t.Fatalf("GetFiles() = %d files, want 3", len(files))
+	}
+	if f := files[1001]; f.FileName != "a.jar" || f.DownloadUrl != srv.URL+"/dl/1001" {
+		t.Errorf("files[1001] = %+v", f)
+	}
+	if f := files[1002]; f.DownloadUrl != "" {
+		t.Errorf("files[1002].DownloadUrl = %s, want empty", f.DownloadUrl)
+	}
+
+	_, err = newCurseClient(srv.URL, "wrong").GetFiles(context.Background(), []int{1001})
+	if err == nil {
+		t.Errorf("GetFiles() with wrong key error = nil")
+	}
+}
+
+func TestLocalInstaller_InstallCurseBatch(t *testing.T) {
+	srv := newTestCurseServer(t, map[int][2]string{
+		1001: {"a.jar", "a"},
+		1002: {"b.jar", "b"},
+	})
+	srv.disallowed[1002] = true
+	defer func(host string) { cf_cdn_host = host }(cf_cdn_host)
+	cf_cdn_host = srv.URL
+
+	var mods []*Mod
+	for id, f := range srv.files {
+		mods = append(mods, &Mod{
+			Path:       "mods/" + f[0],
+			Hash:       sha256Hex(f[1]),
+			HashFormat: "sha256",
+			Downloads:  &Download{Type: DL_Curseforge, Data: fmt.Sprintf("1:%d", id)},
+		})
+	}
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(&Pack{Url: srv.URL, Mods: mods}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.CurseClient = newCurseClient(srv.URL, "key")
+
+	res, err := inst.Install(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Added) != 2 {
+		t.Errorf("Added = %d, want 2", len(res.Added))
+	}
+	if len(srv.batches) != 1 || srv.singles != 0 {
+		t.Errorf("batch requests = %d, single requests = %d, want 1, 0", len(srv.batches), srv.singles)
+	}
+	for name, want := range map[string]string{"a.jar": "a", "b.jar": "b"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "mods", name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestLocalInstaller_InstallCurseCdn(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		hash       string
+		wantManual bool
+	}{
+		{name: "downloaded", hash: sha256Hex("a")},
+		{name: "not on the cdn", path: "/missing", hash: sha256Hex("a"), wantManual: true},
+		{name: "hash mismatch", hash: sha256Hex("tampered")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestCurseServer(t, map[int][2]string{1001: {"a.jar", "a"}})
+			defer func(host string) { cf_cdn_host = host }(cf_cdn_host)
+			cf_cdn_host = srv.URL + tt.path
+
+			mod := &Mod{
+				Path:       "mods/a.jar",
+				Filename:   "a.jar",
+				Hash:       tt.hash,
+				HashFormat: "sha256",
+				Downloads:  &Download{Type: DL_Curseforge, Data: "1:1001"},
+			}
+			inst, err := NewLocalInstaller(&Pack{Url: srv.URL, Mods: []*Mod{mod}}, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			inst.CurseClient = newCurseClient(srv.URL, "")
+
+			_, err = inst.Install(context.Background())
+			var manualErr *ManualDownloadError
+			if got := errors.As(err, &manualErr); got != tt.wantManual {
+				t.Errorf("Install() error = %v, want ManualDownloadError %v", err, tt.wantManual)
+			}
+			if tt.hash != sha256Hex("a") && err == nil {
+				t.Errorf("Install() of a mismatched file error = nil")
+			}
+		})
+	}
+}
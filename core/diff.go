@@ -0,0 +1,168 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ModChange is a mod present in both packs with different contents.
+type ModChange struct {
+	Old *Mod `json:"old"`
+	New *Mod `json:"new"`
+}
+
+type PackDiff struct {
+	Old     PackIdentity `json:"old"`
+	New     PackIdentity `json:"new"`
+	Added   []*Mod       `json:"added"`
+	Removed []*Mod       `json:"removed"`
+	Updated []*ModChange `json:"updated"`
+}
+
+// diffKey identifies a mod across pack versions. Mods defined by a metafile
+// keep the metafile path while their file name changes.
+func diffKey(m *Mod) string {
+	if m.Metafile != "" {
+		return m.Metafile
+	}
+	return m.Path
+}
+
+// DiffPacks compares the mods of two packs.
+func DiffPacks(old, new *Pack) *PackDiff {
+	var d = &PackDiff{
+		Old:     old.Identity(),
+		New:     new.Identity(),
+		Updated: []*ModChange{},
+	}
+	a, r, same := diffSliceFunc(
+		append([]*Mod(nil), old.Mods...),
+		append([]*Mod(nil), new.Mods...),
+		func(a, b *Mod) int {
+			return cmp.Compare(diffKey(a), diffKey(b))
+		},
+	)
+	// empty lists rather than null in JSON
+	d.Added = append([]*Mod{}, a...)
+	d.Removed = append([]*Mod{}, r...)
+
+	oldMods := make(map[string]*Mod, len(old.Mods))
+	for _, m := range old.Mods {
+		oldMods[diffKey(m)] = m
+	}
+	for _, m := range same {
+		o := oldMods[diffKey(m)]
+		if o.Path != m.Path || o.Hash != m.Hash || o.Side != m.Side {
+			d.Updated = append(d.Updated, &ModChange{Old: o, New: m})
+		}
+	}
+	return d
+}
+
+func (d *PackDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+func sourceString(s *ModSource) string {
+	if s == nil {
+		return ""
+	}
+	var ids []string
+	if s.CurseForge != nil {
+		ids = append(ids, fmt.Sprintf("curseforge %d/%d", s.CurseForge.ProjectID, s.CurseForge.FileID))
+	}
+	if s.Modrinth != nil {
+		ids = append(ids, fmt.Sprintf("modrinth %s/%s", s.Modrinth.ModID, s.Modrinth.VersionID))
+	}
+	return strings.Join(ids, ", ")
+}
+
+func (c *ModChange) label() string {
+	if c.New.Name != "" {
+		return fmt.Sprintf("%s (%s)", diffKey(c.New), c.New.Name)
+	}
+	return diffKey(c.New)
+}
+
+// markdownLabel links the name of m to its project page if known.
+func markdownLabel(m *Mod) string {
+	if m.Name == "" {
+		return fmt.Sprintf("`%s`", m.Path)
+	}
+	if u := m.PageUrl(); u != "" {
+		return fmt.Sprintf("[%s](%s) `%s`", m.Name, u, path.Base(m.Path))
+	}
+	return fmt.Sprintf("%s `%s`", m.Name, path.Base(m.Path))
+}
+
+// details lists what changed between the old and new mod.
+func (c *ModChange) details() []string {
+	var s []string
+	if c.Old.Path != c.New.Path {
+		s = append(s, fmt.Sprintf("file: %s -> %s", path.Base(c.Old.Path), path.Base(c.New.Path)))
+	} else if c.Old.Hash != c.New.Hash {
+		s = append(s, fmt.Sprintf("file: %s (content changed)", path.Base(c.New.Path)))
+	}
+	if o, n := sourceString(c.Old.Source), sourceString(c.New.Source); o != n {
+		s = append(s, fmt.Sprintf("source: %s -> %s", o, n))
+	}
+	if c.Old.Side != c.New.Side {
+		s = append(s, fmt.Sprintf("side: %s -> %s", c.Old.Side, c.New.Side))
+	}
+	return s
+}
+
+func (d *PackDiff) String() string {
+	var s string
+	s += "Added:\n"
+	for _, m := range d.Added {
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += "Removed:\n"
+	for _, m := range d.Removed {
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += "Updated:\n"
+	for _, c := range d.Updated {
+		s += fmt.Sprintf("  %s\n", c.label())
+		for _, line := range c.details() {
+			s += fmt.Sprintf("    %s\n", line)
+		}
+	}
+	return s
+}
+
+// Markdown formats the diff for a changelog.
+func (d *PackDiff) Markdown() string {
+	var s string
+	if d.Old.Version != d.New.Version {
+		s += fmt.Sprintf("## %s %s -> %s\n\n", d.New.Name, d.Old.Version, d.New.Version)
+	}
+	if len(d.Added) > 0 {
+		s += "### Added\n"
+		for _, m := range d.Added {
+			s += fmt.Sprintf("- %s\n", markdownLabel(m))
+		}
+		s += "\n"
+	}
+	if len(d.Removed) > 0 {
+		s += "### Removed\n"
+		for _, m := range d.Removed {
+			s += fmt.Sprintf("- %s\n", markdownLabel(m))
+		}
+		s += "\n"
+	}
+	if len(d.Updated) > 0 {
+		s += "### Updated\n"
+		for _, c := range d.Updated {
+			s += fmt.Sprintf("- %s: %s\n", c.label(), strings.Join(c.details(), ", "))
+		}
+		s += "\n"
+	}
+	if d.Empty() {
+		s += "No changes.\n"
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffPacks(t *testing.T) {
+	old := &Pack{Name: "p", Version: "1.0", Mods: []*Mod{
+		{Path: "config/a.cfg", Hash: "a1"},
+		{Path: "config/b.cfg", Hash: "b1"},
+		{Path: "mods/sodium-0.5.jar", Hash: "s1", Side: Side_Client, Metafile: "mods/sodium.pw.toml"},
+		{Path: "mods/iris.jar", Hash: "i1", Side: Side_Client, Metafile: "mods/iris.pw.toml"},
+		{Path: "mods/lithium.jar", Hash: "l1", Side: Side_Both, Metafile: "mods/lithium.pw.toml"},
+	}}
+	new := &Pack{Name: "p", Version: "1.1", Mods: []*Mod{
+		{Path: "config/a.cfg", Hash: "a2"},
+		{Path: "config/c.cfg", Hash: "c1"},
+		{Path: "mods/sodium-0.6.jar", Hash: "s2", Side: Side_Client, Metafile: "mods/sodium.pw.toml"},
+		{Path: "mods/iris.jar", Hash: "i1", Side: Side_Both, Metafile: "mods/iris.pw.toml"},
+		{Path: "mods/lithium.jar", Hash: "l1", Side: Side_Both, Metafile: "mods/lithium.pw.toml"},
+	}}
+
+	d := DiffPacks(old, new)
+	keys := func(mods []*Mod) []string {
+		var s []string
+		for _, m := range mods {
+			s = append(s, diffKey(m))
+		}
+		return s
+	}
+	if got := keys(d.Added); !slices.Equal(got, []string{"config/c.cfg"}) {
+		t.Errorf("Added = %v", got)
+	}
+	if got := keys(d.Removed); !slices.Equal(got, []string{"config/b.cfg"}) {
+		t.Errorf("Removed = %v", got)
+	}
+	var updated []string
+	for _, c := range d.Updated {
+		updated = append(updated, diffKey(c.New))
+	}
+	if !slices.Equal(updated, []string{"config/a.cfg", "mods/iris.pw.toml", "mods/sodium.pw.toml"}) {
+		t.Errorf("Updated = %v", updated)
+	}
+	if len(old.Mods) != 5 || old.Mods[0].Path != "config/a.cfg" {
+		t.Errorf("DiffPacks() modified the old pack")
+	}
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pruneDirs removes the empty directories among dirs and their parents up to
+// base, deepest first. Entries in removed count as already gone, which lets a
+// dry run predict the result. Only directories which owned reports are
+// removed. Removed directories are added to removed and returned.
+func pruneDirs(base string, dirs []string, removed map[string]bool, dryRun bool, owned func(string) bool) ([]string, error) {
+	var (
+		pruned  []string
+		pending = map[string]bool{}
+	)
+	for _, d := range dirs {
+		pending[d] = true
+	}
+
+	for len(pending) > 0 {
+		// deepest first, so parents see their children removed
+		var queue = make([]string, 0, len(pending))
+		for d := range pending {
+			queue = append(queue, d)
+		}
+		slices.SortFunc(queue, func(a, b string) int {
+			return cmp.Or(
+				-cmp.Compare(strings.Count(a, string(filepath.Separator)), strings.Count(b, string(filepath.Separator))),
+				cmp.Compare(a, b),
+			)
+		})
+		d := queue[0]
+		delete(pending, d)
+
+		if d == base || !isWithin(base, d) || removed[d] || !owned(d) {
+			continue
+		}
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return pruned, err
+		}
+		empty := true
+		for _, e := range entries {
+			if !removed[filepath.Join(d, e.Name())] {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(d); err != nil {
+				return pruned, err
+			}
+		}
+		removed[d] = true
+		pruned = append(pruned, d)
+		pending[filepath.Dir(d)] = true
+	}
+	return pruned, nil
+}
+
+// mkdirAll creates the directory p and its parents like os.MkdirAll, and
+// records the ones it creates, which become owned by the installer.
+func (i *LocalInstaller) mkdirAll(p string) error {
+	var missing []string
+	for d := p; d != i.BaseDir && isWithin(i.BaseDir, d); d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(p, dirPerm); err != nil {
+		return err
+	}
+
+	i.dirsMu.Lock()
+	defer i.dirsMu.Unlock()
+	if i.createdDirs == nil {
+		i.createdDirs = map[string]bool{}
+	}
+	for _, d := range missing {
+		i.createdDirs[d] = true
+	}
+	return nil
+}
+
+// ownedDirs returns the directories created by the installer, from prev of
+// the state and by mkdirAll, which still exist. They are relative slash paths
+// as stored in the state.
+func (i *LocalInstaller) ownedDirs(prev []string) []string {
+	i.dirsMu.Lock()
+	defer i.dirsMu.Unlock()
+
+	var dirs []string
+	for _, d := range prev {
+		if p, err := safeJoin(i.BaseDir, d); err == nil {
+			dirs = append(dirs, p)
+		}
+	}
+	for d := range i.createdDirs {
+		dirs = append(dirs, d)
+	}
+
+	var owned []string
+	for _, d := range dirs {
+		if stat, err := os.Lstat(d); err != nil || !stat.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(i.BaseDir, d)
+		if err != nil {
+			continue
+		}
+		if rel := filepath.ToSlash(rel); !slices.Contains(owned, rel) {
+			owned = append(owned, rel)
+		}
+	}
+	slices.Sort(owned)
+	return owned
+}
+
+// pruneOwnedDirs removes the empty directories among the parents of paths,
+// up to BaseDir, which the installer created. User directories are kept.
+// It returns the pruned directories as relative slash paths.
+func (i *LocalInstaller) pruneOwnedDirs(paths []string, prev []string) ([]string, error) {
+	owned := ownedDirSet(i.BaseDir, i.ownedDirs(prev))
+	var dirs []string
+	for _, p := range paths {
+		dirs = append(dirs, filepath.Dir(filepath.Join(i.BaseDir, filepath.FromSlash(p))))
+	}
+
+	pruned, err := pruneDirs(i.BaseDir, dirs, map[string]bool{}, false, owned)
+	var rels []string
+	for _, d := range pruned {
+		if rel, err := filepath.Rel(i.BaseDir, d); err == nil {
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+	}
+	return rels, err
+}
+
+// ownedDirSet returns a func reporting whether a directory is one of dirs,
+// relative slash paths of the state, for pruneDirs.
+func ownedDirSet(base string, dirs []string) func(string) bool {
+	owned := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		if p, err := safeJoin(base, d); err == nil {
+			owned[p] = true
+		}
+	}
+	return func(d string) bool { return owned[d] }
+}
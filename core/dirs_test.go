@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLocalInstaller_InstallPrunesDirs(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{
+		"config/oldmod/a.cfg": "a",
+		"kubejs/server.js":    "s",
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// made by the user before the install
+	if err := os.Mkdir(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"config/oldmod", "kubejs"}; !slices.Equal(state.Dirs, want) {
+		t.Errorf("state dirs = %v, want %v", state.Dirs, want)
+	}
+
+	srv.setFiles(map[string]string{"mods/a.jar": "a"})
+	res, err := newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(res.Pruned)
+	if want := []string{"config/oldmod", "kubejs"}; !slices.Equal(res.Pruned, want) {
+		t.Errorf("Pruned = %v, want %v", res.Pruned, want)
+	}
+	for name, want := range map[string]bool{"config": true, "config/oldmod": false, "kubejs": false, "mods": true} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+	state, err = LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"mods"}; !slices.Equal(state.Dirs, want) {
+		t.Errorf("state dirs = %v, want %v", state.Dirs, want)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	packwiz "github.com/packwiz/packwiz/core"
+)
+
+// Supported pack formats are packwiz:1.0.0 and later 1.x versions.
+var (
+	packFormatPrefix     = "packwiz:"
+	packFormatMinVersion = [3]int{1, 0, 0}
+	packFormatMaxMajor   = 1
+)
+
+type UnsupportedFormatError struct {
+	Format string
+	Reason string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported pack format %q: %s (use --ignore-format to install anyway)", e.Format, e.Reason)
+}
+
+func supportedPackFormats() string {
+	v := packFormatMinVersion
+	return fmt.Sprintf("%s%d.%d.%d to %s%d.x", packFormatPrefix, v[0], v[1], v[2], packFormatPrefix, packFormatMaxMajor)
+}
+
+func parsePackFormat(s string) ([3]int, error) {
+	var v [3]int
+	ver, ok := strings.CutPrefix(s, packFormatPrefix)
+	if !ok {
+		return v, fmt.Errorf("not a packwiz format")
+	}
+	parts := strings.Split(ver, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q", ver)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", ver)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// checkPackFormat checks that the pack-format of pack.toml is one the
+// installer understands. An empty format is the oldest packwiz format.
+func checkPackFormat(s string) error {
+	if s == "" {
+		return nil
+	}
+	v, err := parsePackFormat(s)
+	if err != nil {
+		return &UnsupportedFormatError{s, err.Error()}
+	}
+	for i := range v {
+		if v[i] != packFormatMinVersion[i] {
+			if v[i] < packFormatMinVersion[i] {
+				return &UnsupportedFormatError{s, "supported formats are " + supportedPackFormats()}
+			}
+			break
+		}
+	}
+	if v[0] > packFormatMaxMajor {
+		return &UnsupportedFormatError{s, "supported formats are " + supportedPackFormats()}
+	}
+	return nil
+}
+
+func checkHashFormat(format string, where string) error {
+	if _, err := packwiz.GetHashImpl(format); err != nil {
+		return fmt.Errorf("unsupported hash format %q in %s", format, where)
+	}
+	return nil
+}
+
+func checkIndexHashFormats(index *IndexToml) error {
+	if err := checkHashFormat(index.HashFormat, "index"); err != nil {
+		return err
+	}
+	for _, f := range index.Files {
+		if f.HashFormat == "" {
+			continue
+		}
+		if err := checkHashFormat(f.HashFormat, "index entry "+f.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
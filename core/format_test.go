@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckPackFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: ""},
+		{format: "packwiz:1.0.0"},
+		{format: "packwiz:1.1.0"},
+		{format: "packwiz:1.12.3"},
+		{format: "packwiz:0.9.0", wantErr: true},
+		{format: "packwiz:2.0.0", wantErr: true},
+		{format: "packwiz:1.1", wantErr: true},
+		{format: "packwiz:1.x.0", wantErr: true},
+		{format: "packwzi:1.1.0", wantErr: true},
+		{format: "1.1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := checkPackFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPackFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			}
+			var unsupported *UnsupportedFormatError
+			if err != nil && !errors.As(err, &unsupported) {
+				t.Errorf("checkPackFormat(%q) error = %v, want UnsupportedFormatError", tt.format, err)
+			}
+		})
+	}
+}
+
+func TestCheckIndexHashFormats(t *testing.T) {
+	index := &IndexToml{
+		HashFormat: "sha256",
+		Files: []IndexedfileToml{
+			{File: "a.txt"},
+			{File: "b.txt", HashFormat: "md5"},
+		},
+	}
+	if err := checkIndexHashFormats(index); err != nil {
+		t.Errorf("checkIndexHashFormats() error = %v", err)
+	}
+
+	index.Files = append(index.Files, IndexedfileToml{File: "c.txt", HashFormat: "crc32"})
+	if err := checkIndexHashFormats(index); err == nil {
+		t.Errorf("checkIndexHashFormats() unknown hash error = nil")
+	}
+}
@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/carlmjohnson/requests"
 )
@@ -14,6 +20,60 @@ var (
 	defaultRequestBuilder = newRequestBuilder(http.DefaultClient)
 )
 
+// NewLocalFileClient returns a client which also reads file:// URLs, to load
+// packs from a local working tree. Remote packs must not be loaded with it,
+// as their metafiles and redirects could then read any local file.
+func NewLocalFileClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.RegisterProtocol("file", fileTransport{})
+	return &http.Client{Transport: t}
+}
+
+// fileTransport serves file:// URLs from the local disk, so packs can be
+// loaded from a working tree.
+type fileTransport struct{}
+
+func (fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := req.URL.Path
+	// file:///C:/pack.toml
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	res := &http.Response{
+		Proto:      "HTTP/1.0",
+		ProtoMajor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	f, err := os.Open(filepath.FromSlash(p))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		res.StatusCode = http.StatusNotFound
+		res.Status = "404 Not Found"
+		res.Body = io.NopCloser(strings.NewReader(""))
+		return res, nil
+	}
+	res.StatusCode = http.StatusOK
+	res.Status = "200 OK"
+	res.Body = f
+	return res, nil
+}
+
+// FileUrl returns the file:// URL of a local path.
+func FileUrl(p string) (*url.URL, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	return &url.URL{Scheme: "file", Path: abs}, nil
+}
+
 func newRequestBuilder(c *http.Client) *requests.Builder {
 	return requests.New().
 		Client(c).
@@ -31,13 +91,60 @@ func httpGetBytes(ctx context.Context, c *http.Client, url string) ([]byte, erro
 		Client(c).
 		BaseURL(url).
 		ToBytesBuffer(buf).
-		Fetch(context.WithoutCancel(ctx))
+		Fetch(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
+// httpValidators holds the cache validators of a previous response.
+type httpValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// httpGetConditionalBytes sends a conditional GET using prev.
+// When the server answers 304 Not Modified, data is nil and modified is false.
+func httpGetConditionalBytes(ctx context.Context, c *http.Client, url string, prev httpValidators) (data []byte, next httpValidators, modified bool, err error) {
+	var (
+		buf    = &bytes.Buffer{}
+		header = http.Header{}
+		status int
+	)
+	rb := defaultRequestBuilder.
+		Clone().
+		Client(c).
+		BaseURL(url).
+		CheckStatus(http.StatusOK, http.StatusNotModified).
+		AddValidator(func(res *http.Response) error {
+			status = res.StatusCode
+			return nil
+		}).
+		CopyHeaders(header).
+		ToBytesBuffer(buf)
+	if prev.ETag != "" {
+		rb.Header("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		rb.Header("If-Modified-Since", prev.LastModified)
+	}
+
+	err = rb.Fetch(ctx)
+	if err != nil {
+		return nil, prev, false, err
+	}
+	if status == http.StatusNotModified {
+		return nil, prev, false, nil
+	}
+
+	next = httpValidators{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	return buf.Bytes(), next, true, nil
+}
+
 func httpGetValidBytes(ctx context.Context, c *http.Client, url string, hashFormat string, hash string) ([]byte, error) {
 	data, err := httpGetBytes(ctx, c, url)
 	if err != nil {
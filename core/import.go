@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DefaultImportDir returns the Downloads folder of the user, where browsers
+// save the files of ManualDownloadError by default.
+func DefaultImportDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Downloads"), nil
+}
+
+// importStamp is the size and mtime of a file checked by ImportFiles.
+type importStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// ImportFiles moves the files in dir whose hash matches one of mods into
+// place and records them in the installed state. Only files with the same
+// extension as a mod are hashed, and files which matched none of the mods at
+// an earlier call are hashed again only when they changed, as it is called
+// repeatedly while waiting for downloads. It returns the imported mods.
+func (i *LocalInstaller) ImportFiles(ctx context.Context, dir string, mods []*Mod) ([]*Mod, error) {
+	unlock, err := i.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		imported []*Mod
+		pending  = slices.Clone(mods)
+	)
+	for _, e := range entries {
+		if len(pending) == 0 {
+			break
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		ext := filepath.Ext(e.Name())
+		if !slices.ContainsFunc(pending, func(m *Mod) bool { return path.Ext(m.Path) == ext }) {
+			continue
+		}
+
+		src := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		stamp := importStamp{size: info.Size(), modTime: info.ModTime()}
+		if i.importChecked[src] == stamp {
+			continue
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		idx := slices.IndexFunc(pending, func(m *Mod) bool {
+			ok, err := MatchHash(data, m.HashFormat, m.Hash)
+			return err == nil && ok
+		})
+		if idx == -1 {
+			if i.importChecked == nil {
+				i.importChecked = map[string]importStamp{}
+			}
+			i.importChecked[src] = stamp
+			continue
+		}
+
+		m := pending[idx]
+		if err := i.importFile(src, data, m); err != nil {
+			return nil, fmt.Errorf("import %s: %w", m.Path, err)
+		}
+		imported = append(imported, m)
+		pending = slices.Delete(pending, idx, idx+1)
+	}
+	if len(imported) == 0 {
+		return nil, nil
+	}
+
+	state, err := i.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("load state: %w", err)
+	}
+	state.Files = slices.DeleteFunc(state.Files, func(s *Mod) bool {
+		return slices.ContainsFunc(imported, func(m *Mod) bool { return m.Path == s.Path })
+	})
+	state.Files = append(state.Files, imported...)
+	state.Manual = slices.DeleteFunc(state.Manual, func(s *Mod) bool {
+		return slices.ContainsFunc(imported, func(m *Mod) bool { return m.Path == s.Path })
+	})
+	state.Dirs = i.ownedDirs(state.Dirs)
+	if err := state.save(i.BaseDir); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	return imported, nil
+}
+
+// importFile writes data read from src to the path of m with the mode of
+// installed files, and removes src. src is not renamed into place, as it
+// has the mode given by the browser.
+func (i *LocalInstaller) importFile(src string, data []byte, m *Mod) error {
+	p, err := i.modPath(m)
+	if err != nil {
+		return err
+	}
+	if err := i.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	perm, preserve := i.filePerm(p, m)
+	if err := writeFileAtomic(p, data, perm); err != nil {
+		return err
+	}
+	// the previous mode is kept as is, regardless of umask
+	if preserve {
+		if err := os.Chmod(p, perm); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(src); err != nil {
+		return err
+	}
+	return i.fingerprint(m)
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLocalInstaller_ImportFiles(t *testing.T) {
+	srv := newTestCurseServer(t, map[int][2]string{1001: {"a.jar", "a"}})
+	srv.disallowed[1001] = true
+	defer func(host string) { cf_cdn_host = host }(cf_cdn_host)
+	cf_cdn_host = srv.URL + "/missing"
+
+	mod := &Mod{
+		Path:       "mods/a.jar",
+		Hash:       sha256Hex("a"),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Curseforge, Data: "1:1001"},
+	}
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(&Pack{Url: srv.URL, Mods: []*Mod{mod}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.CurseClient = newCurseClient(srv.URL, "key")
+	ctx := context.Background()
+
+	_, err = inst.Install(ctx)
+	var manualErr *ManualDownloadError
+	if !errors.As(err, &manualErr) || len(manualErr.Mods) != 1 {
+		t.Fatalf("Install() error = %v, want ManualDownloadError", err)
+	}
+	plan, err := inst.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Manual) != 1 || plan.Changed() {
+		t.Errorf("Plan() Manual = %v, Changed() = %v, want only the manual file", plan.Manual, plan.Changed())
+	}
+
+	downloads := t.TempDir()
+	for name, body := range map[string]string{"a (1).jar": "a", "other.jar": "other", "a.txt": "a"} {
+		if err := os.WriteFile(filepath.Join(downloads, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imported, err := inst.ImportFiles(ctx, downloads, manualErr.Mods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("ImportFiles() = %d mods, want 1", len(imported))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "mods", "a.jar")); string(data) != "a" {
+		t.Errorf("a.jar = %q, want %q", data, "a")
+	}
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(filepath.Join(dir, "mods", "a.jar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Mode().Perm() == 0o600 {
+			t.Errorf("a.jar mode = %v, want the mode of installed files, not of the download", stat.Mode())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(downloads, "a (1).jar")); !os.IsNotExist(err) {
+		t.Errorf("downloaded file is not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(downloads, "a.txt")); err != nil {
+		t.Errorf("unrelated file is moved: %v", err)
+	}
+
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Files) != 1 || state.Files[0].Path != "mods/a.jar" {
+		t.Errorf("state files = %v", state.Files)
+	}
+	if len(state.Manual) != 0 {
+		t.Errorf("state manual = %v, want none", state.Manual)
+	}
+}
+
+func TestLocalInstaller_ImportFilesChecksChangedFiles(t *testing.T) {
+	mod := &Mod{Path: "mods/a.jar", Hash: sha256Hex("a"), HashFormat: "sha256"}
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(&Pack{Url: "https://example.com/pack.toml", Mods: []*Mod{mod}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	downloads := t.TempDir()
+	p := filepath.Join(downloads, "b.jar")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(body string, mtime time.Time) {
+		t.Helper()
+		writeTestFile(t, p, body)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("b", mtime)
+	if imported, err := inst.ImportFiles(ctx, downloads, []*Mod{mod}); err != nil || len(imported) != 0 {
+		t.Fatalf("ImportFiles() = %v, %v", imported, err)
+	}
+	// the same size and mtime is not read again
+	write("a", mtime)
+	if imported, err := inst.ImportFiles(ctx, downloads, []*Mod{mod}); err != nil || len(imported) != 0 {
+		t.Fatalf("ImportFiles() of an unchanged file = %v, %v", imported, err)
+	}
+	write("a", mtime.Add(time.Second))
+	if imported, err := inst.ImportFiles(ctx, downloads, []*Mod{mod}); err != nil || len(imported) != 1 {
+		t.Fatalf("ImportFiles() of a changed file = %v, %v", imported, err)
+	}
+}
@@ -1,38 +1,77 @@
 package core
 
 import (
-	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/carlmjohnson/requests"
 	"golang.org/x/sync/errgroup"
 )
 
 type Updates struct {
-	Added     []*Mod
-	Removed   []*Mod
-	Unchanged []*Mod
+	Added []*Mod `json:"added"`
+	// Updated are the files whose content changes at the same path.
+	Updated   []*Mod `json:"updated"`
+	Removed   []*Mod `json:"removed"`
+	Unchanged []*Mod `json:"unchanged"`
+	// Damaged are the unchanged files which failed the integrity check and
+	// are installed again.
+	Damaged []*DamagedFile `json:"damaged,omitempty"`
+	// Pruned are the directories created by installs which are removed as
+	// they became empty.
+	Pruned []string `json:"pruned,omitempty"`
+	// Unsafe are the files of the state outside of the install directory,
+	// which are dropped from it without being removed.
+	Unsafe []*Mod `json:"unsafe,omitempty"`
+	// Changelog is set by Install.
+	Changelog *Changelog `json:"-"`
 }
 
 func (u *Updates) String() string {
 	var s string
 	s += "Added:\n"
 	for _, m := range u.Added {
-		s += fmt.Sprintf("  %s\n", m.Path)
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += "Updated:\n"
+	for _, m := range u.Updated {
+		s += fmt.Sprintf("  %s\n", m)
 	}
 	s += "Removed:\n"
 	for _, m := range u.Removed {
-		s += fmt.Sprintf("  %s\n", m.Path)
+		s += fmt.Sprintf("  %s\n", m)
 	}
 	s += "Unchanged:\n"
 	for _, m := range u.Unchanged {
-		s += fmt.Sprintf("  %s\n", m.Path)
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	if len(u.Damaged) > 0 {
+		s += "Damaged:\n"
+		for _, d := range u.Damaged {
+			s += fmt.Sprintf("  %s\n", d)
+		}
+	}
+	if len(u.Pruned) > 0 {
+		s += "Pruned directories:\n"
+		for _, d := range u.Pruned {
+			s += fmt.Sprintf("  %s/\n", d)
+		}
+	}
+	if len(u.Unsafe) > 0 {
+		s += "Unsafe paths in the state, not removed:\n"
+		for _, m := range u.Unsafe {
+			s += fmt.Sprintf("  %s\n", m)
+		}
 	}
 	return s
 }
@@ -44,9 +83,55 @@ type Installer interface {
 }
 
 type LocalInstaller struct {
-	BaseDir    string
-	Pack       *Pack
-	httpClient *http.Client
+	BaseDir string
+	Pack    *Pack
+	// FullCheck makes Install rehash every unchanged file instead of
+	// trusting matching size and mtime fingerprints.
+	FullCheck bool
+	// Side selects the mods for a client or server. Empty installs all sides.
+	Side Side
+	// Options chooses optional mods by Mod.OptionKey. Unlisted optional mods
+	// use their default.
+	Options map[string]bool
+	// Force allows installing over a directory that holds another pack.
+	Force bool
+	// LockTimeout is how long to wait for another install into BaseDir to finish.
+	LockTimeout time.Duration
+	// PreserveMode keeps the mode of existing files when they are replaced.
+	PreserveMode bool
+	// BeforeChange is called by Install after every download succeeded,
+	// before the first file is changed. An error aborts the install with
+	// the files unchanged.
+	BeforeChange func(ctx context.Context) error
+	CurseClient  *CurseClient
+	httpClient   *http.Client
+	// curseFiles are resolved by resolveCurse before downloading.
+	curseFiles map[int]*CurseFile
+	// importChecked are the files which matched no mod at ImportFiles.
+	importChecked map[string]importStamp
+	// createdDirs are the absolute directories created by mkdirAll.
+	createdDirs map[string]bool
+	dirsMu      sync.Mutex
+}
+
+// ManualDownloadError lists the mods which could not be downloaded
+// automatically and have to be downloaded by the player.
+type ManualDownloadError struct {
+	Mods []*Mod
+}
+
+func (e *ManualDownloadError) Error() string {
+	var s = "these files must be downloaded manually:"
+	for _, m := range e.Mods {
+		s += fmt.Sprintf("\n  %s", m)
+		if u := m.BrowserUrl(); u != "" {
+			s += fmt.Sprintf("\n    download: %s", u)
+		}
+		if u := m.PageUrl(); u != "" {
+			s += fmt.Sprintf("\n    project:  %s", u)
+		}
+	}
+	return s
 }
 
 func NewLocalInstaller(p *Pack, dir string) (*LocalInstaller, error) {
@@ -55,161 +140,449 @@ func NewLocalInstaller(p *Pack, dir string) (*LocalInstaller, error) {
 		return nil, err
 	}
 	return &LocalInstaller{
-		BaseDir:    abs,
-		Pack:       p,
-		httpClient: http.DefaultClient,
+		BaseDir:     abs,
+		Pack:        p,
+		LockTimeout: time.Minute,
+		CurseClient: DefaultCurseClient,
+		httpClient:  http.DefaultClient,
 	}, nil
 }
 
+// CacheDir returns the directory where the installer keeps its state for baseDir.
+func CacheDir(baseDir string) string {
+	return filepath.Join(baseDir, ".pw-install")
+}
+
+func (i *LocalInstaller) cachePath(name string) string {
+	return filepath.Join(CacheDir(i.BaseDir), fmt.Sprintf("%s.json", name))
+}
+
 func (i *LocalInstaller) saveCache(name string, v any) error {
-	p := filepath.Join(i.BaseDir, ".pw-install", fmt.Sprintf("%s.json", name))
-	data, err := json.MarshalIndent(v, "", "  ")
+	return saveJsonFile(i.cachePath(name), v)
+}
+
+// lock takes the install lock of BaseDir. The returned func releases it.
+func (i *LocalInstaller) lock(ctx context.Context) (func() error, error) {
+	err := os.MkdirAll(CacheDir(i.BaseDir), dirPerm)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+	return acquireLock(ctx, lockPath(i.BaseDir), i.LockTimeout)
+}
+
+func (i *LocalInstaller) loadState() (*State, error) {
+	state, err := LoadState(i.BaseDir)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if state.Pack.Same(i.Pack.Identity()) {
+		// optional mods chosen at the last install stay chosen
+		options := maps.Clone(state.Options)
+		if options == nil {
+			options = map[string]bool{}
+		}
+		maps.Copy(options, i.Options)
+		i.Options = options
+	}
+	if state.Pack.Url == "" || state.Pack.Same(i.Pack.Identity()) || i.Force {
+		return state, nil
+	}
+	return nil, &PackMismatchError{
+		Installed: state.Pack,
+		Pack:      i.Pack.Identity(),
 	}
-	return os.WriteFile(p, data, os.ModePerm)
 }
 
-func (i *LocalInstaller) restoreCache(name string, v any) error {
-	p := filepath.Join(i.BaseDir, ".pw-install", fmt.Sprintf("%s.json", name))
-	if _, err := os.Stat(p); err != nil {
-		if os.IsNotExist(err) {
-			return nil
+func (i *LocalInstaller) saveState(mods []*Mod, manual []*Mod, dirs []string) error {
+	state := &State{
+		Pack:        i.Pack.Identity(),
+		InstalledAt: time.Now().UTC(),
+		Side:        i.Side,
+		Options:     i.Options,
+		Files:       mods,
+		Dirs:        dirs,
+		Manual:      manual,
+	}
+	return state.save(i.BaseDir)
+}
+
+// wants reports whether m is installed with the chosen side and options.
+// Optional mods not chosen in Options follow their default.
+func (i *LocalInstaller) wants(m *Mod) bool {
+	if !m.MatchSide(i.Side) {
+		return false
+	}
+	if m.Option != nil {
+		if v, ok := i.Options[m.OptionKey()]; ok {
+			return v
+		}
+		return m.Option.Default
+	}
+	return true
+}
+
+func (i *LocalInstaller) targetMods() []*Mod {
+	var mods = make([]*Mod, 0, len(i.Pack.Mods))
+	for _, m := range i.Pack.Mods {
+		if i.wants(m) {
+			mods = append(mods, m)
 		}
-		return err
 	}
+	return mods
+}
+
+// modPath returns the local path of m, which is guaranteed to be inside BaseDir.
+func (i *LocalInstaller) modPath(m *Mod) (string, error) {
+	return safeJoin(i.BaseDir, m.Path)
+}
 
-	data, err := os.ReadFile(p)
+// fingerprint records size and mtime of the installed file of m.
+func (i *LocalInstaller) fingerprint(m *Mod) error {
+	p, err := i.modPath(m)
 	if err != nil {
 		return err
 	}
-
-	if err = json.Unmarshal(data, &v); err != nil {
+	stat, err := os.Stat(p)
+	if err != nil {
 		return err
 	}
+	m.Size = stat.Size()
+	m.ModTime = stat.ModTime().UnixNano()
 	return nil
 }
 
-func (i *LocalInstaller) setInstalledMods() error {
-	return i.saveCache("installed", i.Pack.Mods)
-}
-
-func (i *LocalInstaller) getInstalledMods() ([]*Mod, error) {
-	var mods []*Mod
-	err := i.restoreCache("installed", &mods)
+func (i *LocalInstaller) GetUpdates() (*Updates, error) {
+	state, err := i.loadState()
 	if err != nil {
 		return nil, err
 	}
-	return mods, nil
+	return i.getUpdates(state.Files, i.targetMods()), nil
 }
 
-func (i *LocalInstaller) checkIntegrity(m *Mod) (bool, error) {
-	// existence
-	p := filepath.Join(i.BaseDir, m.Path)
-	stat, err := os.Stat(p)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return false, nil
+// getUpdates compares the installed files with target by path. A path is
+// never both installed and removed.
+func (i *LocalInstaller) getUpdates(installed, target []*Mod) *Updates {
+	var (
+		u    = &Updates{}
+		prev = make(map[string]*Mod, len(installed))
+		next = make(map[string]bool, len(target))
+	)
+	for _, m := range installed {
+		prev[m.Path] = m
+	}
+	for _, m := range target {
+		next[m.Path] = true
+		o, ok := prev[m.Path]
+		switch {
+		case !ok:
+			u.Added = append(u.Added, m)
+		case o.Hash != m.Hash || o.HashFormat != m.HashFormat:
+			u.Updated = append(u.Updated, m)
+		default:
+			u.Unchanged = append(u.Unchanged, m)
 		}
-		if stat.IsDir() {
-			return false, nil
+	}
+	for _, m := range installed {
+		if !next[m.Path] {
+			u.Removed = append(u.Removed, m)
 		}
-		return false, err
 	}
+	return u
+}
 
-	// hash
-	data, err := os.ReadFile(p)
+// InstallMod downloads m and writes it in place.
+func (i *LocalInstaller) InstallMod(ctx context.Context, m *Mod) error {
+	p, err := i.modPath(m)
 	if err != nil {
-		return false, err
+		return err
 	}
-	valid, err := MatchHash(data, m.HashFormat, m.Hash)
+	data, err := i.download(ctx, m)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return valid, nil
-}
-
-func (i *LocalInstaller) GetUpdates() (*Updates, error) {
-	installed, err := i.getInstalledMods()
+	// do not start writing once canceled
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err = i.mkdirAll(filepath.Dir(p))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	perm, preserve := i.filePerm(p, m)
+	err = writeFileAtomic(p, data, perm)
+	if err != nil {
+		return err
 	}
-	a, r, u := diffSliceFunc(installed, i.Pack.Mods, func(a, b *Mod) int {
-		res := cmp.Compare(a.Path, b.Path)
-		if res == 0 && a.Hash != b.Hash {
-			res = -1
+	// the previous mode is kept as is, regardless of umask
+	if preserve {
+		if err := os.Chmod(p, perm); err != nil {
+			return err
 		}
-		return res
-	})
-	return &Updates{
-		Added:     a,
-		Removed:   r,
-		Unchanged: u,
-	}, nil
+	}
+	return i.fingerprint(m)
 }
 
-func (i *LocalInstaller) InstallMod(ctx context.Context, m *Mod) error {
-	var (
-		data []byte
-		err  error
-	)
+// download fetches the file of m and verifies its hash.
+func (i *LocalInstaller) download(ctx context.Context, m *Mod) ([]byte, error) {
 	switch m.Downloads.Type {
 	case DL_Url:
-		data, err = httpGetValidBytes(ctx, i.httpClient, m.Downloads.Data, m.HashFormat, m.Hash)
-		if err != nil {
-			return err
-		}
+		return httpGetValidBytes(ctx, i.httpClient, m.Downloads.Data, m.HashFormat, m.Hash)
 	case DL_Curseforge:
 		cfData, err := ParseCfData(m.Downloads.Data)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		u, err := DefaultCurseClient.GetDownloadUrl(ctx, cfData)
+		u, err := i.curseDownloadUrl(ctx, cfData)
 		if err != nil {
+			return nil, err
+		}
+		// no api key, or the author disallowed distribution
+		if u == "" {
+			return i.downloadCurseCdn(ctx, m, cfData)
+		}
+		return httpGetValidBytes(ctx, i.httpClient, u, m.HashFormat, m.Hash)
+	}
+	return nil, fmt.Errorf("unknown download type %q", m.Downloads.Type)
+}
+
+// stage downloads m into a new file in dir, to be moved into place by commit.
+func (i *LocalInstaller) stage(ctx context.Context, m *Mod, dir string) (string, error) {
+	p, err := i.modPath(m)
+	if err != nil {
+		return "", err
+	}
+	data, err := i.download(ctx, m)
+	if err != nil {
+		return "", err
+	}
+
+	perm, _ := i.filePerm(p, m)
+	f, err := createTemp(filepath.Join(dir, path.Base(m.Path)), perm)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return f.Name(), err
+}
+
+// commit moves the file staged for m into place.
+func (i *LocalInstaller) commit(m *Mod, staged string) error {
+	p, err := i.modPath(m)
+	if err != nil {
+		return err
+	}
+	if err := i.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	perm, preserve := i.filePerm(p, m)
+	// rename fails across devices
+	if err := os.Rename(staged, p); err != nil {
+		data, rerr := os.ReadFile(staged)
+		if rerr != nil {
 			return err
 		}
-		data, err = httpGetValidBytes(ctx, i.httpClient, u, m.HashFormat, m.Hash)
-		if err != nil {
+		if err := writeFileAtomic(p, data, perm); err != nil {
+			return err
+		}
+	}
+	// the previous mode is kept as is, regardless of umask
+	if preserve {
+		if err := os.Chmod(p, perm); err != nil {
 			return err
 		}
 	}
+	return i.fingerprint(m)
+}
 
-	p := filepath.Join(i.BaseDir, m.Path)
-	err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+// curseDownloadUrl returns the download URL of a CurseForge file, from the
+// files resolved by resolveCurse or else by its own request. It is empty when
+// there is no api key or the file has no download URL.
+func (i *LocalInstaller) curseDownloadUrl(ctx context.Context, d *CurseforgeData) (string, error) {
+	if !i.CurseClient.HasKey() {
+		return "", nil
+	}
+	if f, ok := i.curseFiles[d.FileID]; ok {
+		return f.DownloadUrl, nil
+	}
+	return i.CurseClient.GetDownloadUrl(ctx, d)
+}
+
+// downloadCurseCdn fetches a CurseForge file from the CDN without api key.
+// It fails with ManualDownloadError when the CDN does not have or serve the
+// file, and with other errors as they are.
+func (i *LocalInstaller) downloadCurseCdn(ctx context.Context, m *Mod, d *CurseforgeData) ([]byte, error) {
+	filename := m.Filename
+	if f, ok := i.curseFiles[d.FileID]; ok && f.FileName != "" {
+		filename = f.FileName
+	}
+	if filename == "" {
+		filename = path.Base(m.Path)
+	}
+	data, err := httpGetValidBytes(ctx, i.httpClient, CurseCdnUrl(d.FileID, filename), m.HashFormat, m.Hash)
+	if requests.HasStatusErr(err, http.StatusForbidden, http.StatusNotFound) {
+		return nil, &ManualDownloadError{Mods: []*Mod{m}}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// resolveCurse resolves the CurseForge files of mods in batches before
+// anything is written, which also verifies the api key.
+func (i *LocalInstaller) resolveCurse(ctx context.Context, mods []*Mod) error {
+	if !i.CurseClient.HasKey() {
+		return nil
+	}
+	var ids []int
+	for _, m := range mods {
+		if m.Downloads.Type != DL_Curseforge {
+			continue
+		}
+		d, err := ParseCfData(m.Downloads.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Path, err)
+		}
+		ids = append(ids, d.FileID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	files, err := i.CurseClient.GetFiles(ctx, ids)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(p, data, os.ModePerm)
+	i.curseFiles = files
+	return nil
 }
 
-// Install execute install and update modpack
+// removeTempFiles removes the temp files left by an interrupted install in
+// the cache and the directories of mods.
+func (i *LocalInstaller) removeTempFiles(mods ...[]*Mod) error {
+	var dirs = []string{CacheDir(i.BaseDir)}
+	for _, ms := range mods {
+		for _, m := range ms {
+			p, err := i.modPath(m)
+			if err != nil {
+				continue
+			}
+			if d := filepath.Dir(p); !slices.Contains(dirs, d) {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	return removeTempFiles(dirs)
+}
+
+// filePerm returns the mode to write the file of m at p with. preserve is
+// true when it is the mode of the existing file kept by PreserveMode.
+func (i *LocalInstaller) filePerm(p string, m *Mod) (perm os.FileMode, preserve bool) {
+	if i.PreserveMode {
+		if stat, err := os.Stat(p); err == nil && stat.Mode().IsRegular() {
+			return stat.Mode().Perm(), true
+		}
+	}
+	if m.Executable {
+		return execPerm, false
+	}
+	return filePerm, false
+}
+
+// ensureExecutable adds the execute bits to the installed file of m if the
+// pack marks it executable after it was installed.
+func (i *LocalInstaller) ensureExecutable(m *Mod) error {
+	if !m.Executable {
+		return nil
+	}
+	p, err := i.modPath(m)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if stat.Mode().Perm()&0o111 != 0 {
+		return nil
+	}
+	return os.Chmod(p, stat.Mode().Perm()|(execPerm&0o111))
+}
+
+// newGroup returns an errgroup for one phase of install. Its context is
+// canceled when the phase ends, so each phase needs its own.
+func newGroup(ctx context.Context) (*errgroup.Group, context.Context) {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.NumCPU())
+	return eg, ctx
+}
+
+// Install execute install and update modpack, following the plan computed
+// first. If some files have to be downloaded manually, it returns the result
+// of everything else along with ManualDownloadError. When ctx is canceled,
+// the installed state is left as it was.
 func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 	var result = &Updates{}
-	update, err := i.GetUpdates()
+	unlock, err := i.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := i.loadState()
 	if err != nil {
 		return nil, fmt.Errorf("check updates: %w", err)
 	}
+	if err := i.removeTempFiles(state.Files, i.Pack.Mods); err != nil {
+		return nil, fmt.Errorf("remove temp files: %w", err)
+	}
+	plan, err := i.plan(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("plan: %w", err)
+	}
+	target := plan.target
+	result.Unchanged = plan.Unchanged
+	result.Damaged = plan.Damaged
+	result.Unsafe = plan.Unsafe
 
-	mut := sync.Mutex{}
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(runtime.NumCPU())
-	for _, m := range update.Unchanged {
+	if err := i.resolveCurse(ctx, plan.Install); err != nil {
+		return nil, fmt.Errorf("resolve curseforge files: %w", err)
+	}
+
+	// download everything before changing any file
+	staging, err := os.MkdirTemp(CacheDir(i.BaseDir), "staging-*"+tempSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	var (
+		mut    = sync.Mutex{}
+		staged = make(map[*Mod]string, len(plan.Install))
+		manual []*Mod
+	)
+	eg, egCtx := newGroup(ctx)
+	for _, m := range plan.Install {
 		eg.Go(func() error {
-			ok, err := i.checkIntegrity(m)
+			name, err := i.stage(egCtx, m, staging)
+			var manualErr *ManualDownloadError
+			if errors.As(err, &manualErr) {
+				mut.Lock()
+				manual = append(manual, manualErr.Mods...)
+				mut.Unlock()
+				return nil
+			}
 			if err != nil {
-				return fmt.Errorf("check integrity: %w", err)
+				return fmt.Errorf("install mod: %w", err)
 			}
 			mut.Lock()
-			if ok {
-				result.Unchanged = append(result.Unchanged, m)
-			} else {
-				update.Added = append(update.Added, m)
-			}
+			staged[m] = name
 			mut.Unlock()
 			return nil
 		})
@@ -218,14 +591,30 @@ func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 		return nil, err
 	}
 
-	for _, m := range update.Added {
+	// keep the files of the previous install when canceled
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if i.BeforeChange != nil && (len(staged) > 0 || len(plan.Remove) > 0 || len(plan.Dirs) > 0) {
+		if err := i.BeforeChange(ctx); err != nil {
+			return nil, err
+		}
+	}
+	eg, _ = newGroup(ctx)
+	for _, m := range plan.Remove {
 		eg.Go(func() error {
-			err := i.InstallMod(ctx, m)
+			p, err := i.modPath(m)
 			if err != nil {
-				return fmt.Errorf("install mod: %w", err)
+				return fmt.Errorf("remove mod: %w", err)
+			}
+			err = os.Remove(p)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return fmt.Errorf("remove mod: %w", err)
+				}
 			}
 			mut.Lock()
-			result.Added = append(result.Added, m)
+			result.Removed = append(result.Removed, m)
 			mut.Unlock()
 			return nil
 		})
@@ -233,29 +622,75 @@ func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
+	for _, d := range plan.Dirs {
+		p, err := safeJoin(i.BaseDir, d)
+		if err != nil {
+			return nil, err
+		}
+		if err := removeDirTree(p); err != nil {
+			return nil, fmt.Errorf("remove directory in the way: %w", err)
+		}
+	}
 
-	for _, m := range update.Removed {
+	eg, _ = newGroup(ctx)
+	for _, m := range plan.Install {
+		name, ok := staged[m]
+		if !ok {
+			continue
+		}
 		eg.Go(func() error {
-			p := filepath.Join(i.BaseDir, m.Path)
-			err := os.Remove(p)
-			if err != nil {
-				if !os.IsNotExist(err) {
-					return fmt.Errorf("remove mod: %w", err)
-				}
+			if err := i.commit(m, name); err != nil {
+				return fmt.Errorf("install mod: %w", err)
 			}
 			mut.Lock()
-			result.Removed = append(result.Removed, m)
+			// repaired files are only listed in Damaged
+			if plan.updated[m] {
+				result.Updated = append(result.Updated, m)
+			} else if !plan.damaged[m] {
+				result.Added = append(result.Added, m)
+			}
 			mut.Unlock()
 			return nil
 		})
 	}
+	for _, m := range result.Unchanged {
+		eg.Go(func() error {
+			if err := i.ensureExecutable(m); err != nil {
+				return fmt.Errorf("set mode: %w", err)
+			}
+			return nil
+		})
+	}
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
 
-	err = i.setInstalledMods()
+	var gone = slices.Clone(plan.Dirs)
+	for _, m := range result.Removed {
+		gone = append(gone, m.Path)
+	}
+	result.Pruned, err = i.pruneOwnedDirs(gone, state.Dirs)
+	if err != nil {
+		return nil, fmt.Errorf("prune directories: %w", err)
+	}
+
+	// files to download manually are not installed yet
+	installed := slices.DeleteFunc(slices.Clone(target), func(m *Mod) bool {
+		return slices.Contains(manual, m)
+	})
+	err = i.saveState(installed, manual, i.ownedDirs(state.Dirs))
 	if err != nil {
 		return nil, fmt.Errorf("save cache: %w", err)
 	}
+
+	result.Changelog = newChangelog(state, i.Pack, result)
+	if !result.Changelog.Empty() {
+		if err := result.Changelog.save(i.BaseDir); err != nil {
+			return nil, fmt.Errorf("save changelog: %w", err)
+		}
+	}
+	if len(manual) > 0 {
+		return result, &ManualDownloadError{Mods: manual}
+	}
 	return result, nil
 }
@@ -0,0 +1,398 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestInstaller(t *testing.T, srv *testPackServer, dir string) *LocalInstaller {
+	t.Helper()
+	repo := NewRepository(srv.packUrl(t), "", "")
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst
+}
+
+func TestLocalInstaller_InstallFingerprint(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"config/a.txt": "aaaa"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper without changing size or mtime
+	p := filepath.Join(dir, "config", "a.txt")
+	stat, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte("bbbb"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Unchanged) != 1 || len(res.Added) != 0 {
+		t.Errorf("quick check: Added = %d, Unchanged = %d, want 0, 1", len(res.Added), len(res.Unchanged))
+	}
+
+	inst := newTestInstaller(t, srv, dir)
+	inst.FullCheck = true
+	res, err = inst.Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Unchanged) != 0 || len(res.Damaged) != 1 || len(res.Added) != 0 {
+		t.Errorf("full check: Added = %d, Damaged = %d, Unchanged = %d, want 0, 1, 0", len(res.Added), len(res.Damaged), len(res.Unchanged))
+	}
+	if data, _ := os.ReadFile(p); string(data) != "aaaa" {
+		t.Errorf("full check: content = %q, want %q", data, "aaaa")
+	}
+}
+
+func TestLocalInstaller_InstallCanceled(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "a1", "mods/b.jar": "b1"})
+	dir := t.TempDir()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(statePath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.setFiles(map[string]string{"mods/a.jar": "a1", "mods/c.jar": "c2"})
+	slow := "mods/c.jar"
+	srv.slow.Store(&slow)
+	inst := newTestInstaller(t, srv, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := inst.Install(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Install() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Install() is not canceled")
+	}
+
+	after, err := os.ReadFile(statePath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("state is changed by canceled install")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mods", "c.jar")); !os.IsNotExist(err) {
+		t.Errorf("c.jar is written by canceled install: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "mods", "b.jar")); string(data) != "b1" {
+		t.Errorf("b.jar = %q, want previous %q", data, "b1")
+	}
+
+	// the next install completes from the previous state
+	srv.slow.Store(nil)
+	if _, err := newTestInstaller(t, srv, dir).Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.jar": "a1", "c.jar": "c2"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "mods", name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mods", "b.jar")); !os.IsNotExist(err) {
+		t.Errorf("b.jar is not removed: %v", err)
+	}
+}
+
+func TestLocalInstaller_InstallFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+	srv := newTestPackServer(t, map[string]string{"start.sh": "#!/bin/sh", "a.txt": "a", "b.txt": "b"})
+	dir := t.TempDir()
+	mods := func() []*Mod {
+		var mods []*Mod
+		for _, name := range []string{"start.sh", "a.txt", "b.txt"} {
+			mods = append(mods, &Mod{
+				Path:       name,
+				Hash:       sha256Hex(srv.files[name]),
+				HashFormat: "sha256",
+				Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/" + name},
+				Executable: name == "start.sh",
+			})
+		}
+		return mods
+	}
+	install := func(preserve bool) {
+		t.Helper()
+		inst, err := NewLocalInstaller(&Pack{Url: srv.URL, Mods: mods()}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.PreserveMode = preserve
+		inst.FullCheck = true
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mode := func(name string) os.FileMode {
+		t.Helper()
+		stat, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stat.Mode().Perm()
+	}
+
+	install(false)
+	if m := mode("start.sh"); m&0o100 == 0 {
+		t.Errorf("start.sh mode = %v, want executable", m)
+	}
+	defaultMode := mode("a.txt")
+	if defaultMode&0o111 != 0 || defaultMode&0o002 != 0 {
+		t.Errorf("a.txt mode = %v, want 0644 or stricter", defaultMode)
+	}
+
+	// replaced files keep their mode only with PreserveMode
+	for name, body := range map[string]string{"a.txt": "x", "b.txt": "x"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	install(true)
+	if m := mode("a.txt"); m != 0o600 {
+		t.Errorf("preserved a.txt mode = %v, want %v", m, os.FileMode(0o600))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("y"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	install(false)
+	if m := mode("b.txt"); m != defaultMode {
+		t.Errorf("b.txt mode = %v, want %v", m, defaultMode)
+	}
+}
+
+func TestLocalInstaller_GetUpdates(t *testing.T) {
+	var (
+		a1 = &Mod{Path: "mods/a.jar", Hash: "a1"}
+		a2 = &Mod{Path: "mods/a.jar", Hash: "a2"}
+		b1 = &Mod{Path: "mods/b.jar", Hash: "b1"}
+		c1 = &Mod{Path: "mods/c.jar", Hash: "c1"}
+		d1 = &Mod{Path: "mods/d.jar", Hash: "d1"}
+	)
+	inst := &LocalInstaller{}
+	u := inst.getUpdates([]*Mod{a1, b1, c1}, []*Mod{c1, a2, d1})
+
+	paths := func(mods []*Mod) []string {
+		var s []string
+		for _, m := range mods {
+			s = append(s, m.Path+":"+m.Hash)
+		}
+		return s
+	}
+	for name, tt := range map[string]struct{ got, want []string }{
+		"Added":     {paths(u.Added), []string{"mods/d.jar:d1"}},
+		"Updated":   {paths(u.Updated), []string{"mods/a.jar:a2"}},
+		"Removed":   {paths(u.Removed), []string{"mods/b.jar:b1"}},
+		"Unchanged": {paths(u.Unchanged), []string{"mods/c.jar:c1"}},
+	} {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLocalInstaller_InstallSameNameUpdate(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "a1", "mods/b.jar": "b1", "mods/c.jar": "c1"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.setFiles(map[string]string{"mods/a.jar": "a2", "mods/b.jar": "b2", "mods/c.jar": "c1"})
+
+	res, err := newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Updated) != 2 || len(res.Added) != 0 || len(res.Removed) != 0 || len(res.Unchanged) != 1 {
+		t.Errorf("Install() Added = %d, Updated = %d, Removed = %d, Unchanged = %d, want 0, 2, 0, 1",
+			len(res.Added), len(res.Updated), len(res.Removed), len(res.Unchanged))
+	}
+	for name, want := range map[string]string{"a.jar": "a2", "b.jar": "b2", "c.jar": "c1"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "mods", name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+
+	// the next install finds everything in place
+	res, err = newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Unchanged) != 3 {
+		t.Errorf("reinstall Unchanged = %d, want 3", len(res.Unchanged))
+	}
+}
+
+func TestLocalInstaller_TargetMods(t *testing.T) {
+	pack := &Pack{Mods: []*Mod{
+		{Path: "mods/common.jar"},
+		{Path: "mods/client.jar", Side: Side_Client},
+		{Path: "mods/server.jar", Side: Side_Server},
+		{Path: "mods/on.jar", Metafile: "mods/on.pw.toml", Option: &ModOption{Default: true}},
+		{Path: "mods/off.jar", Metafile: "mods/off.pw.toml", Option: &ModOption{Default: false}},
+	}}
+	tests := []struct {
+		name    string
+		side    Side
+		options map[string]bool
+		want    []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"mods/common.jar", "mods/client.jar", "mods/server.jar", "mods/on.jar"},
+		},
+		{
+			name: "side",
+			side: Side_Server,
+			want: []string{"mods/common.jar", "mods/server.jar", "mods/on.jar"},
+		},
+		{
+			name:    "options over defaults",
+			options: map[string]bool{"off": true},
+			want:    []string{"mods/common.jar", "mods/client.jar", "mods/server.jar", "mods/on.jar", "mods/off.jar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst, err := NewLocalInstaller(pack, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			inst.Side = tt.side
+			inst.Options = tt.options
+			var got []string
+			for _, m := range inst.targetMods() {
+				got = append(got, m.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("targetMods() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalInstaller_InstallKeepsOptions(t *testing.T) {
+	srv := newTestPackServer(t, nil)
+	srv.setFiles(map[string]string{
+		"files/off.jar": "off",
+		"mods/off.pw.toml": fmt.Sprintf(`name = "Off"
+filename = "off.jar"
+[download]
+url = "%s/files/off.jar"
+hash-format = "sha256"
+hash = %q
+[option]
+optional = true
+default = false
+`, srv.URL, sha256Hex("off")),
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+	jar := filepath.Join(dir, "mods", "off.jar")
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(jar); !os.IsNotExist(err) {
+		t.Fatalf("optional mod off by default installed: %v", err)
+	}
+
+	inst := newTestInstaller(t, srv, dir)
+	inst.Options = map[string]bool{"off": true}
+	if _, err := inst.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the choice is kept by the next install without options
+	res, err := newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 0 {
+		t.Errorf("Install() without options removed %v", res.Removed)
+	}
+	if _, err := os.Stat(jar); err != nil {
+		t.Errorf("chosen optional mod removed: %v", err)
+	}
+}
+
+func TestLocalInstaller_InstallBeforeChange(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"config/a.txt": "a"})
+	dir := t.TempDir()
+	ctx := context.Background()
+	p := filepath.Join(dir, "config", "a.txt")
+
+	inst := newTestInstaller(t, srv, dir)
+	inst.BeforeChange = func(ctx context.Context) error {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("file changed before BeforeChange: %v", err)
+		}
+		return errors.New("server is busy")
+	}
+	if _, err := inst.Install(ctx); err == nil || err.Error() != "server is busy" {
+		t.Fatalf("Install() error = %v, want the error of BeforeChange", err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("file changed after BeforeChange failed: %v", err)
+	}
+
+	// not called without changes
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	inst = newTestInstaller(t, srv, dir)
+	inst.BeforeChange = func(ctx context.Context) error {
+		t.Errorf("BeforeChange called without changes")
+		return nil
+	}
+	if _, err := inst.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Integrity is the result of checking an installed file.
+type Integrity string
+
+const (
+	// Integrity_Intact files are kept.
+	Integrity_Intact = Integrity("intact")
+	// Integrity_Missing files are downloaded.
+	Integrity_Missing = Integrity("missing")
+	// Integrity_Mismatched files have other content and are replaced.
+	Integrity_Mismatched = Integrity("mismatched")
+	// Integrity_Unreadable files cannot be hashed, e.g. for lack of
+	// permission, and are replaced.
+	Integrity_Unreadable = Integrity("unreadable")
+	// Integrity_Directory is a directory at the path of a file. It is removed
+	// when empty, otherwise install fails to keep its contents.
+	Integrity_Directory = Integrity("directory")
+)
+
+// DamagedFile is an installed file which failed the integrity check.
+type DamagedFile struct {
+	Mod    *Mod      `json:"file"`
+	Status Integrity `json:"status"`
+}
+
+func (d *DamagedFile) String() string {
+	return fmt.Sprintf("%s (%s)", d.Mod, d.Status)
+}
+
+// checkIntegrity checks the installed file of m. prev is the record of the
+// previous install, whose fingerprint allows skipping the rehash. An error
+// is returned only when the file cannot be checked at all.
+func (i *LocalInstaller) checkIntegrity(m *Mod, prev *Mod) (Integrity, error) {
+	// existence
+	p, err := i.modPath(m)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			return Integrity_Missing, nil
+		}
+		return "", err
+	}
+	if stat.IsDir() {
+		return Integrity_Directory, nil
+	}
+
+	// fingerprint
+	if !i.FullCheck && prev != nil && prev.Size != 0 && prev.Hash == m.Hash &&
+		prev.Size == stat.Size() && prev.ModTime == stat.ModTime().UnixNano() {
+		m.Size = prev.Size
+		m.ModTime = prev.ModTime
+		return Integrity_Intact, nil
+	}
+
+	// hash
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return Integrity_Unreadable, nil
+	}
+	valid, err := MatchHash(data, m.HashFormat, m.Hash)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return Integrity_Mismatched, nil
+	}
+	m.Size = stat.Size()
+	m.ModTime = stat.ModTime().UnixNano()
+	return Integrity_Intact, nil
+}
+
+// clearDamaged prepares the path of a damaged file to be written again.
+// A directory in the way is removed only when it holds no files, as in the
+// plan of Install.
+func (i *LocalInstaller) clearDamaged(m *Mod, status Integrity) error {
+	if status != Integrity_Directory {
+		return nil
+	}
+	p, err := i.modPath(m)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(p, func(sub string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		return fmt.Errorf("%s is a directory with files in it, move it away to install the file", m.Path)
+	})
+	if err != nil {
+		return err
+	}
+	return removeDirTree(p)
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLocalInstaller_CheckIntegrity(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, p string)
+		hashFormat string
+		want       Integrity
+		wantErr    bool
+		wantClear  bool
+		unreadable bool
+	}{
+		{
+			name:  "intact",
+			setup: func(t *testing.T, p string) { writeTestFile(t, p, "a") },
+			want:  Integrity_Intact,
+		},
+		{
+			name:      "missing",
+			setup:     func(t *testing.T, p string) {},
+			want:      Integrity_Missing,
+			wantClear: true,
+		},
+		{
+			name:      "mismatched",
+			setup:     func(t *testing.T, p string) { writeTestFile(t, p, "b") },
+			want:      Integrity_Mismatched,
+			wantClear: true,
+		},
+		{
+			name: "unreadable",
+			setup: func(t *testing.T, p string) {
+				writeTestFile(t, p, "a")
+				if err := os.Chmod(p, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       Integrity_Unreadable,
+			wantClear:  true,
+			unreadable: true,
+		},
+		{
+			name: "empty directory",
+			setup: func(t *testing.T, p string) {
+				if err := os.MkdirAll(p, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:      Integrity_Directory,
+			wantClear: true,
+		},
+		{
+			name: "directory of empty directories",
+			setup: func(t *testing.T, p string) {
+				if err := os.MkdirAll(filepath.Join(p, "a", "b"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:      Integrity_Directory,
+			wantClear: true,
+		},
+		{
+			name:       "unknown hash format",
+			setup:      func(t *testing.T, p string) { writeTestFile(t, p, "a") },
+			hashFormat: "crc32",
+			wantErr:    true,
+		},
+		{
+			name:  "directory with files",
+			setup: func(t *testing.T, p string) { writeTestFile(t, filepath.Join(p, "mine.txt"), "") },
+			want:  Integrity_Directory,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unreadable && (runtime.GOOS == "windows" || os.Geteuid() == 0) {
+				t.Skip("permissions are not enforced")
+			}
+			dir := t.TempDir()
+			m := &Mod{Path: "mods/a.jar", Hash: sha256Hex("a"), HashFormat: cmp.Or(tt.hashFormat, "sha256")}
+			tt.setup(t, filepath.Join(dir, "mods", "a.jar"))
+
+			inst, err := NewLocalInstaller(&Pack{}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := inst.checkIntegrity(m, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkIntegrity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("checkIntegrity() = %s, want %s", got, tt.want)
+			}
+			if got == Integrity_Intact {
+				return
+			}
+			if err := inst.clearDamaged(m, got); (err == nil) != tt.wantClear {
+				t.Errorf("clearDamaged() error = %v, want cleared %v", err, tt.wantClear)
+			}
+		})
+	}
+}
+
+func TestLocalInstaller_InstallDamaged(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "a", "mods/b.jar": "b"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "mods", "a.jar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "mods", "b.jar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mods", "b.jar"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := newTestInstaller(t, srv, dir).Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]Integrity{}
+	for _, d := range res.Damaged {
+		got[d.Mod.Path] = d.Status
+	}
+	if got["mods/a.jar"] != Integrity_Missing || got["mods/b.jar"] != Integrity_Directory {
+		t.Errorf("Damaged = %v", got)
+	}
+	if len(res.Added) != 0 {
+		t.Errorf("Added = %v, want repaired files only in Damaged", res.Added)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "mods", "b.jar")); string(data) != "b" {
+		t.Errorf("b.jar = %q, want %q", data, "b")
+	}
+}
+
+func writeTestFile(t *testing.T, p string, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// lockStaleAge is the age after which a lock is considered stale even if
+	// its process seems alive, in case the pid was reused.
+	lockStaleAge = 6 * time.Hour
+	// lockWriteGrace is the time allowed to write the lock info after creating it.
+	lockWriteGrace   = 5 * time.Second
+	lockPollInterval = 100 * time.Millisecond
+
+	// heldLocks are the absolute paths of the locks held by this process.
+	heldLocks   = map[string]bool{}
+	heldLocksMu sync.Mutex
+)
+
+func lockPath(baseDir string) string {
+	return cacheLockPath(CacheDir(baseDir))
+}
+
+// cacheLockPath is lockPath by the cache dir, which Repository knows.
+func cacheLockPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "lock")
+}
+
+type lockInfo struct {
+	Pid  int       `json:"pid"`
+	Time time.Time `json:"time"`
+}
+
+type LockedError struct {
+	Path  string
+	Pid   int
+	Since time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("another install is running (pid %d since %s), remove %s if it is not", e.Pid, e.Since.Format(time.RFC3339), e.Path)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// FindProcess fails on Windows if the process does not exist
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+func readLock(p string) (*lockInfo, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var info lockInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.Pid == 0 {
+		// being written or corrupted
+		return &lockInfo{Time: stat.ModTime()}, nil
+	}
+	return &info, nil
+}
+
+// stale reports whether the lock p with info l is left by a process which
+// has exited. A lock with the pid of this process is stale when this process
+// does not hold it, as a restarted container gets the same pid such as 1.
+func (l *lockInfo) stale(p string) bool {
+	age := time.Since(l.Time)
+	if l.Pid == 0 {
+		return age > lockWriteGrace
+	}
+	if l.Pid == os.Getpid() {
+		return !holdsLock(p)
+	}
+	return age > lockStaleAge || !processAlive(l.Pid)
+}
+
+func holdsLock(p string) bool {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	return heldLocks[absLockPath(p)]
+}
+
+func setHeldLock(p string, held bool) {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	if held {
+		heldLocks[absLockPath(p)] = true
+	} else {
+		delete(heldLocks, absLockPath(p))
+	}
+}
+
+func absLockPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+func tryLock(p string) (bool, error) {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm)
+	if err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	// held before the pid is written, so other goroutines never see it stale
+	setHeldLock(p, true)
+	data, err := json.Marshal(lockInfo{Pid: os.Getpid(), Time: time.Now()})
+	if err != nil {
+		f.Close()
+		setHeldLock(p, false)
+		os.Remove(p)
+		return false, err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		setHeldLock(p, false)
+		os.Remove(p)
+		return false, err
+	}
+	return true, nil
+}
+
+// acquireLock creates the lock file p, waiting up to timeout for another
+// holder to release it. Stale locks of dead processes are taken over.
+func acquireLock(ctx context.Context, p string, timeout time.Duration) (release func() error, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLock(p)
+		if err != nil {
+			return nil, fmt.Errorf("lock: %w", err)
+		}
+		if ok {
+			return func() error {
+				setHeldLock(p, false)
+				return os.Remove(p)
+			}, nil
+		}
+
+		info, err := readLock(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("lock: %w", err)
+		}
+		if info.stale(p) {
+			// another process may have taken over meanwhile
+			if cur, err := readLock(p); err != nil || *cur != *info {
+				continue
+			}
+			err = os.Remove(p)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("remove stale lock: %w", err)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, &LockedError{Path: p, Pid: info.Pid, Since: info.Time}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
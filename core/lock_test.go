@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock_Timeout(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "lock")
+	ctx := context.Background()
+
+	release, err := acquireLock(ctx, p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acquireLock(ctx, p, 200*time.Millisecond)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("acquireLock() held error = %v, want LockedError", err)
+	}
+	if locked.Pid != os.Getpid() {
+		t.Errorf("LockedError.Pid = %d, want %d", locked.Pid, os.Getpid())
+	}
+
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	release, err = acquireLock(ctx, p, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() released error = %v", err)
+	}
+	release()
+}
+
+func TestAcquireLock_Stale(t *testing.T) {
+	// pid of a finished process
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		info lockInfo
+	}{
+		{name: "dead-process", info: lockInfo{Pid: cmd.Process.Pid, Time: time.Now()}},
+		{name: "too-old", info: lockInfo{Pid: os.Getppid(), Time: time.Now().Add(-lockStaleAge - time.Minute)}},
+		// left by a previous run with the same pid, as PID 1 in a container
+		{name: "own-pid", info: lockInfo{Pid: os.Getpid(), Time: time.Now()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "lock")
+			data, _ := json.Marshal(tt.info)
+			if err := os.WriteFile(p, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			release, err := acquireLock(context.Background(), p, 0)
+			if err != nil {
+				t.Fatalf("acquireLock() error = %v", err)
+			}
+			release()
+		})
+	}
+}
+
+// TestLocalInstaller_InstallConcurrent runs installs into the same directory
+// in separate processes, re-executing the test binary with the pack URL and
+// directory in the environment.
+func TestLocalInstaller_InstallConcurrent(t *testing.T) {
+	if u := os.Getenv("PW_TEST_INSTALL_URL"); u != "" {
+		installInProcess(t, u, os.Getenv("PW_TEST_INSTALL_DIR"))
+		return
+	}
+
+	srv := newTestPackServer(t, map[string]string{
+		"config/a.txt": "a",
+		"config/b.txt": "b",
+		"config/c.txt": "c",
+	})
+	dir := t.TempDir()
+
+	cmds := make([]*exec.Cmd, 4)
+	outs := make([]bytes.Buffer, len(cmds))
+	for n := range cmds {
+		cmds[n] = exec.Command(os.Args[0], "-test.run=^TestLocalInstaller_InstallConcurrent$")
+		cmds[n].Env = append(os.Environ(), "PW_TEST_INSTALL_URL="+srv.packUrl(t).String(), "PW_TEST_INSTALL_DIR="+dir)
+		cmds[n].Stdout = &outs[n]
+		cmds[n].Stderr = &outs[n]
+		if err := cmds[n].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for n, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("install #%d: %v\n%s", n, err, outs[n].String())
+		}
+	}
+
+	s, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 3 {
+		t.Errorf("state files = %d, want 3", len(s.Files))
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir(dir), "lock")); !os.IsNotExist(err) {
+		t.Errorf("lock remains after install")
+	}
+}
+
+// installInProcess loads the pack at packUrl with the repository cache of
+// dir and installs it into dir.
+func installInProcess(t *testing.T, packUrl string, dir string) {
+	u, err := url.Parse(packUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	repo := NewRepository(u, "", "", WithCacheDir(CacheDir(dir)))
+	if err := repo.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"path/filepath"
 	"slices"
 	"strconv"
@@ -26,15 +27,106 @@ type Download struct {
 	Data string `json:"data"`
 }
 
+type ModrinthData struct {
+	ModID     string `json:"modId"`
+	VersionID string `json:"versionId,omitempty"`
+}
+
+// ModSource identifies the project a mod was published on.
+type ModSource struct {
+	CurseForge *CurseforgeData `json:"curseforge,omitempty"`
+	Modrinth   *ModrinthData   `json:"modrinth,omitempty"`
+}
+
+type ModOption struct {
+	Default     bool   `json:"default"`
+	Description string `json:"description,omitempty"`
+}
+
 type Mod struct {
 	Path       string    `json:"path"`
 	Hash       string    `json:"hash"`
 	HashFormat string    `json:"hashFormat"`
 	Side       Side      `json:"side,omitempty"`
 	Downloads  *Download `json:"download"`
+	// Metafile is the index path of the metafile the mod was defined by.
+	Metafile string `json:"metafile,omitempty"`
+	// Name and Filename are from the metafile. Filename differs from the
+	// base of Path when the file has an alias.
+	Name     string `json:"name,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	// Option is set when the mod is optional.
+	Option *ModOption `json:"option,omitempty"`
+	Source *ModSource `json:"source,omitempty"`
+	// Size and ModTime fingerprint the installed file, so unchanged files
+	// can be verified without rehashing.
+	Size    int64 `json:"size,omitempty"`
+	ModTime int64 `json:"modTime,omitempty"`
+	// Executable is set for files the pack marks executable.
+	Executable bool `json:"executable,omitempty"`
+}
+
+// DisplayName returns the name of m for players, falling back to its path.
+func (m *Mod) DisplayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Path
+}
+
+// String returns the path of m with its display name if it has one.
+func (m *Mod) String() string {
+	if m.Name != "" {
+		return fmt.Sprintf("%s (%s)", m.Path, m.Name)
+	}
+	return m.Path
+}
+
+// PageUrl returns the project page of m, or an empty string if its source is unknown.
+func (m *Mod) PageUrl() string {
+	switch {
+	case m.Source == nil:
+		return ""
+	case m.Source.Modrinth != nil:
+		return fmt.Sprintf("https://modrinth.com/mod/%s", m.Source.Modrinth.ModID)
+	case m.Source.CurseForge != nil:
+		return fmt.Sprintf("https://www.curseforge.com/projects/%d", m.Source.CurseForge.ProjectID)
+	}
+	return ""
+}
+
+// BrowserUrl returns a URL a player can download the file of m from in a
+// browser, for files which cannot be downloaded automatically.
+func (m *Mod) BrowserUrl() string {
+	switch m.Downloads.Type {
+	case DL_Url:
+		return m.Downloads.Data
+	case DL_Curseforge:
+		d, err := ParseCfData(m.Downloads.Data)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("https://www.curseforge.com/api/v1/mods/%d/files/%d/download", d.ProjectID, d.FileID)
+	}
+	return ""
+}
+
+// OptionKey returns the name used to choose an optional mod, which is its
+// metafile name without extension.
+func (m *Mod) OptionKey() string {
+	return strings.TrimSuffix(path.Base(m.Metafile), ".pw.toml")
+}
+
+// MatchSide reports whether m is installed on side s. An empty s matches all mods.
+func (m *Mod) MatchSide(s Side) bool {
+	if s == "" || s == Side_Both || m.Side == "" || m.Side == Side_Both {
+		return true
+	}
+	return m.Side == s
 }
 
 type Pack struct {
+	Url     string `json:"url"`
 	Name    string `json:"name"`
 	Author  string `json:"author,omitempty"`
 	Version string `json:"version,omitempty"`
@@ -78,6 +170,7 @@ func tomlToPack(
 	metafiles []*MetafileToml,
 ) (*Pack, error) {
 	var ppack = &Pack{
+		Url:     packUrl.String(),
 		Name:    pack.Name,
 		Author:  pack.Author,
 		Version: pack.Version,
@@ -85,6 +178,14 @@ func tomlToPack(
 
 	var mods = make([]*Mod, 0, len(index.Files))
 	for _, f := range index.Files {
+		if err := validatePath(f.File); err != nil {
+			return nil, fmt.Errorf("index: %w", err)
+		}
+		if f.Alias != "" {
+			if err := validatePath(f.Alias); err != nil {
+				return nil, fmt.Errorf("index alias of %s: %w", f.File, err)
+			}
+		}
 		if f.Metafile {
 			i := slices.IndexFunc(metafiles, func(m *MetafileToml) bool {
 				return m.IndexName == f.File
@@ -94,6 +195,15 @@ func tomlToPack(
 			}
 
 			metafile := metafiles[i]
+			if err := validateFilename(metafile.Filename); err != nil {
+				return nil, fmt.Errorf("metafile %s: %w", f.File, err)
+			}
+			if metafile.Download == nil {
+				return nil, fmt.Errorf("metafile %s: missing download", f.File)
+			}
+			if err := checkHashFormat(metafile.Download.HashFormat, "metafile "+f.File); err != nil {
+				return nil, err
+			}
 			var dl = &Download{}
 			switch metafile.Download.Mode {
 			// use url when mode is empty
@@ -116,12 +226,41 @@ func tomlToPack(
 
 			modDir := filepath.ToSlash(filepath.Join(filepath.Dir(pack.Index.File), filepath.Dir(f.File)))
 			modPath := filepath.ToSlash(filepath.Join(modDir, metafile.Filename))
+			// alias replaces the whole install path relative to the pack
+			// root, as packwiz-installer does
+			if f.Alias != "" {
+				modPath = path.Clean(f.Alias)
+			}
 			m := &Mod{
 				Path:       modPath,
 				Hash:       metafile.Download.Hash,
 				HashFormat: metafile.Download.HashFormat,
 				Side:       Side(metafile.Side),
 				Downloads:  dl,
+				Metafile:   f.File,
+				Name:       metafile.Name,
+				Filename:   metafile.Filename,
+			}
+			if metafile.Option != nil && metafile.Option.Optional {
+				m.Option = &ModOption{
+					Default:     metafile.Option.Default,
+					Description: metafile.Option.Description,
+				}
+			}
+			if u := metafile.Update; u != nil && (u.CurseForge != nil || u.Modrinth != nil) {
+				m.Source = &ModSource{}
+				if u.CurseForge != nil {
+					m.Source.CurseForge = &CurseforgeData{
+						ProjectID: u.CurseForge.ProjectId,
+						FileID:    u.CurseForge.FileId,
+					}
+				}
+				if u.Modrinth != nil {
+					m.Source.Modrinth = &ModrinthData{
+						ModID:     u.Modrinth.ModId,
+						VersionID: u.Modrinth.Version,
+					}
+				}
 			}
 
 			mods = append(mods, m)
@@ -137,8 +276,12 @@ func tomlToPack(
 				Data: modUrl.String(),
 			}
 
+			installPath := f.File
+			if f.Alias != "" {
+				installPath = path.Clean(f.Alias)
+			}
 			m := &Mod{
-				Path:       f.File,
+				Path:       installPath,
 				Hash:       f.Hash,
 				HashFormat: hashFmt,
 				Side:       Side_Both,
@@ -148,10 +291,29 @@ func tomlToPack(
 		}
 	}
 
+	for _, pattern := range pack.Options.Executable {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("options: executable %q: %w", pattern, err)
+		}
+		for _, m := range mods {
+			if ok, _ := path.Match(pattern, m.Path); ok {
+				m.Executable = true
+			}
+		}
+	}
+
 	ppack.Mods = mods
 	return ppack, nil
 }
 
+func (p *Pack) Identity() PackIdentity {
+	return PackIdentity{
+		Url:     p.Url,
+		Name:    p.Name,
+		Version: p.Version,
+	}
+}
+
 func NewPack(r *Repository) (*Pack, error) {
 	return tomlToPack(r.Url, r.Pack, r.Index, r.Metafiles)
 }
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadTestPack builds a Pack from the pack.toml, index and metafiles in dir.
+func loadTestPack(t *testing.T, dir string, indexFile string) (*Pack, error) {
+	t.Helper()
+	read := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	pack, err := parsePackToml(read("pack.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack.Index.File = indexFile
+	index, err := parseIndexToml(read(indexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metafiles []*MetafileToml
+	for _, f := range index.Files {
+		if !f.Metafile {
+			continue
+		}
+		m, err := parseMetafileToml(read(path.Join(path.Dir(indexFile), f.File)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.IndexName = f.File
+		metafiles = append(metafiles, m)
+	}
+
+	u, _ := url.Parse("https://example.com/pack/pack.toml")
+	return tomlToPack(u, pack, index, metafiles)
+}
+
+func TestTomlToPack_Alias(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     string
+		wantPaths []string
+		wantUrls  []string
+		wantErr   bool
+	}{
+		{
+			name:  "alias",
+			index: "index.toml",
+			wantPaths: []string{
+				"config/a.cfg",
+				"config/b.cfg",
+				"mods/sodium-fabric-0.5.8.jar",
+				"mods/client/iris.jar",
+			},
+			wantUrls: []string{
+				"https://example.com/pack/config/a.cfg",
+				"https://example.com/pack/defaultconfigs/b.cfg",
+				"https://cdn.modrinth.com/data/AANobbMI/versions/b4hTi3mo/sodium-fabric-0.5.8%2Bmc1.20.1.jar",
+				"455508:5270146",
+			},
+		},
+		{
+			name:  "index in a subdirectory",
+			index: "sub/index.toml",
+			wantPaths: []string{
+				"config/a.cfg",
+				"config/b.cfg",
+				"mods/client/iris.jar",
+			},
+			wantUrls: []string{
+				"https://example.com/pack/sub/config/a.cfg",
+				"https://example.com/pack/sub/defaultconfigs/b.cfg",
+				"455508:5270146",
+			},
+		},
+		{
+			name:    "traversal",
+			index:   "index-traversal.toml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack, err := loadTestPack(t, filepath.Join("testdata", "alias"), tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tomlToPack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var paths, urls []string
+			for _, m := range pack.Mods {
+				paths = append(paths, m.Path)
+				urls = append(urls, m.Downloads.Data)
+			}
+			if !slices.Equal(paths, tt.wantPaths) {
+				t.Errorf("tomlToPack() paths = %v, want %v", paths, tt.wantPaths)
+			}
+			if !slices.Equal(urls, tt.wantUrls) {
+				t.Errorf("tomlToPack() downloads = %v, want %v", urls, tt.wantUrls)
+			}
+		})
+	}
+}
+
+func TestTomlToPack_Metadata(t *testing.T) {
+	pack, err := loadTestPack(t, filepath.Join("testdata", "alias"), "index.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(pack.Mods, func(m *Mod) bool { return m.Metafile == "mods/sodium.pw.toml" })
+	if i == -1 {
+		t.Fatal("sodium not found")
+	}
+	sodium := pack.Mods[i]
+	if sodium.Name != "Sodium" || sodium.Side != Side_Client {
+		t.Errorf("Name, Side = %q, %q", sodium.Name, sodium.Side)
+	}
+	if sodium.Source == nil || sodium.Source.Modrinth == nil || sodium.Source.Modrinth.VersionID != "b4hTi3mo" {
+		t.Errorf("Source = %+v", sodium.Source)
+	}
+	if got := sodium.PageUrl(); got != "https://modrinth.com/mod/AANobbMI" {
+		t.Errorf("PageUrl() = %s", got)
+	}
+
+	i = slices.IndexFunc(pack.Mods, func(m *Mod) bool { return m.Metafile == "mods/iris.pw.toml" })
+	iris := pack.Mods[i]
+	if iris.Option == nil || iris.Option.Default || iris.Option.Description != "Shader support" {
+		t.Errorf("Option = %+v", iris.Option)
+	}
+	if got := iris.PageUrl(); got != "https://www.curseforge.com/projects/455508" {
+		t.Errorf("PageUrl() = %s", got)
+	}
+}
+
+func TestTomlToPack_Executable(t *testing.T) {
+	packUrl, _ := url.Parse("https://example.com/pack/pack.toml")
+	pack, err := parsePackToml([]byte(`
+name = "test"
+pack-format = "packwiz:1.1.0"
+
+[index]
+file = "index.toml"
+hash-format = "sha256"
+hash = ""
+
+[options]
+packwiz-install-executable = ["start.sh", "scripts/*.sh"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := &IndexToml{HashFormat: "sha256", Files: []IndexedfileToml{
+		{File: "start.sh"},
+		{File: "scripts/backup.sh"},
+		{File: "scripts/readme.txt"},
+		{File: "config/start.sh"},
+	}}
+
+	p, err := tomlToPack(packUrl, pack, index, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range p.Mods {
+		if m.Executable {
+			got = append(got, m.Path)
+		}
+	}
+	if want := []string{"start.sh", "scripts/backup.sh"}; !slices.Equal(got, want) {
+		t.Errorf("executable = %v, want %v", got, want)
+	}
+
+	pack.Options.Executable = []string{"["}
+	if _, err := tomlToPack(packUrl, pack, index, nil); err == nil {
+		t.Errorf("tomlToPack() with bad pattern error = nil")
+	}
+}
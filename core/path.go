@@ -0,0 +1,112 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+type UnsafePathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe path %q: %s", e.Path, e.Reason)
+}
+
+// validatePath checks that p is a relative slash-separated path which stays
+// inside the directory it is joined to.
+func validatePath(p string) error {
+	switch {
+	case p == "":
+		return &UnsafePathError{p, "empty"}
+	case strings.ContainsRune(p, 0):
+		return &UnsafePathError{p, "contains NUL"}
+	case strings.Contains(p, `\`):
+		return &UnsafePathError{p, "contains backslash"}
+	case strings.Contains(p, ":"):
+		return &UnsafePathError{p, "contains colon"}
+	case path.IsAbs(p) || filepath.IsAbs(p) || filepath.VolumeName(p) != "":
+		return &UnsafePathError{p, "absolute"}
+	}
+
+	clean := path.Clean(p)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return &UnsafePathError{p, "outside of install directory"}
+	}
+	return nil
+}
+
+// validateFilename checks that name is a single path element.
+func validateFilename(name string) error {
+	if err := validatePath(name); err != nil {
+		return err
+	}
+	if strings.Contains(name, "/") || name == "." || name == ".." {
+		return &UnsafePathError{name, "not a file name"}
+	}
+	return nil
+}
+
+// isWithin reports whether target is base or inside it. Both must be clean.
+func isWithin(base, target string) bool {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// safeJoin joins the relative slash path p to base. It fails if the result
+// would be outside base, lexically or through an existing symlink.
+func safeJoin(base string, p string) (string, error) {
+	if err := validatePath(p); err != nil {
+		return "", err
+	}
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		realBase = base
+	}
+
+	joined := filepath.Join(base, filepath.FromSlash(path.Clean(p)))
+	if !isWithin(base, joined) {
+		return "", &UnsafePathError{p, "outside of install directory"}
+	}
+
+	cur := base
+	for _, elem := range strings.Split(path.Clean(p), "/") {
+		cur = filepath.Join(cur, elem)
+		stat, err := os.Lstat(cur)
+		if err != nil {
+			// the rest is created by the installer, after removing a file
+			// in the way
+			if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+				break
+			}
+			return "", err
+		}
+		if stat.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := filepath.EvalSymlinks(cur)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", &UnsafePathError{p, "dangling symlink"}
+			}
+			return "", err
+		}
+		if !isWithin(realBase, target) {
+			return "", &UnsafePathError{p, "symlink leads outside of install directory"}
+		}
+	}
+
+	return joined, nil
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var hostilePaths = []string{
+	"",
+	".",
+	"..",
+	"../evil.jar",
+	"../../etc/passwd",
+	"mods/../../evil.jar",
+	"mods/../..",
+	"/etc/passwd",
+	"//server/share/evil.jar",
+	`..\evil.jar`,
+	`mods\..\..\evil.jar`,
+	`C:\Windows\evil.jar`,
+	"C:evil.jar",
+	"mods/evil\x00.jar",
+}
+
+func TestValidatePath(t *testing.T) {
+	for _, p := range hostilePaths {
+		var unsafe *UnsafePathError
+		if err := validatePath(p); !errors.As(err, &unsafe) {
+			t.Errorf("validatePath(%q) error = %v, want UnsafePathError", p, err)
+		}
+	}
+
+	for _, p := range []string{"mods/a.jar", "config/dir/a.cfg", "mods/./a.jar", "a/../b.txt", "..a.txt"} {
+		if err := validatePath(p); err != nil {
+			t.Errorf("validatePath(%q) error = %v", p, err)
+		}
+	}
+}
+
+func TestValidateFilename(t *testing.T) {
+	for _, name := range []string{"a/b.jar", "../a.jar", "..", `a\b.jar`} {
+		if err := validateFilename(name); err == nil {
+			t.Errorf("validateFilename(%q) error = nil", name)
+		}
+	}
+	if err := validateFilename("sodium-0.5.jar"); err != nil {
+		t.Errorf("validateFilename() error = %v", err)
+	}
+}
+
+func TestSafeJoin_Symlink(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	for _, d := range []string{filepath.Join(base, "inner"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"out":       outside,
+		"in":        filepath.Join(base, "inner"),
+		"file.jar":  filepath.Join(outside, "file.jar"),
+		"dangling":  filepath.Join(root, "missing"),
+		"inner/up":  root,
+		"inner/rel": "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "mods/a.jar"},
+		{path: "in/a.jar"},
+		{path: "inner/rel/a.jar"},
+		{path: "out/a.jar", wantErr: true},
+		{path: "out", wantErr: true},
+		{path: "file.jar", wantErr: true},
+		{path: "dangling/a.jar", wantErr: true},
+		{path: "inner/up/a.jar", wantErr: true},
+		{path: "../outside/a.jar", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := safeJoin(base, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("safeJoin(%q) = %q, %v, wantErr %v", tt.path, got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzSafeJoin(f *testing.F) {
+	for _, p := range hostilePaths {
+		f.Add(p)
+	}
+	f.Add("mods/a.jar")
+	f.Add("config/../mods/a.jar")
+
+	base := f.TempDir()
+	f.Fuzz(func(t *testing.T, p string) {
+		got, err := safeJoin(base, p)
+		if err != nil {
+			return
+		}
+		if got == base || !isWithin(base, got) {
+			t.Errorf("safeJoin(%q) = %q, outside of %q", p, got, base)
+		}
+	})
+}
+
+func TestLocalInstaller_InstallTamperedState(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"config/a.txt": "a"})
+	root := t.TempDir()
+	dir := filepath.Join(root, "instance")
+	victim := filepath.Join(root, "victim.txt")
+	writeTestFile(t, victim, "keep")
+	writeTestFile(t, filepath.Join(dir, "config", "a.txt"), "old")
+	writeTestFile(t, filepath.Join(dir, "config", "b.txt"), "b")
+
+	inst := newTestInstaller(t, srv, dir)
+	s := &State{Pack: inst.Pack.Identity(), Files: []*Mod{
+		{Path: "config/a.txt", Hash: sha256Hex("old"), HashFormat: "sha256"},
+		{Path: "config/b.txt", Hash: sha256Hex("b"), HashFormat: "sha256"},
+		{Path: "../victim.txt", Hash: "00", HashFormat: "sha256"},
+	}}
+	if err := s.save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := inst.Install(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Unsafe) != 1 || result.Unsafe[0].Path != "../victim.txt" {
+		t.Errorf("Install() unsafe = %v", result.Unsafe)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("file outside of install directory removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("config/b.txt not removed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config", "a.txt")); string(data) != "a" {
+		t.Errorf("config/a.txt = %q, want updated", data)
+	}
+
+	// the unsafe path is dropped from the state
+	result, err = inst.Install(context.Background())
+	if err != nil {
+		t.Fatalf("second Install() error = %v", err)
+	}
+	if len(result.Unsafe) != 0 || len(result.Removed) != 0 {
+		t.Errorf("second Install() = %+v", result)
+	}
+}
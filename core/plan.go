@@ -0,0 +1,269 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Plan is the order of changes of an install, computed before anything is
+// written. Files to install are downloaded first, then Remove and Dirs are
+// deleted, and then the downloaded files are moved into place, so a removal
+// never deletes a file written by the same install.
+type Plan struct {
+	// Remove are the installed files which are not in the pack anymore.
+	Remove []*Mod `json:"remove"`
+	// Dirs are directories in the way of files to install. They only hold
+	// files of Remove and empty directories.
+	Dirs []string `json:"dirs,omitempty"`
+	// Install are the files which are new, updated or damaged.
+	Install []*Mod `json:"install"`
+	// Unchanged are the intact files which are kept.
+	Unchanged []*Mod `json:"unchanged"`
+	// Damaged are the unchanged files which failed the integrity check.
+	Damaged []*DamagedFile `json:"damaged,omitempty"`
+	// Manual are the files of Install which had to be downloaded manually
+	// at the last install, with the same content.
+	Manual []*Mod `json:"manual,omitempty"`
+	// Unsafe are the installed files at paths outside of the install
+	// directory, which only a tampered state has. They are dropped from the
+	// state without being removed.
+	Unsafe []*Mod `json:"unsafe,omitempty"`
+
+	target  []*Mod
+	updated map[*Mod]bool
+	damaged map[*Mod]bool
+}
+
+func (p *Plan) String() string {
+	var s string
+	s += "Remove:\n"
+	for _, m := range p.Remove {
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += "Remove directories:\n"
+	for _, d := range p.Dirs {
+		s += fmt.Sprintf("  %s/\n", d)
+	}
+	s += "Install:\n"
+	for _, m := range p.Install {
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += fmt.Sprintf("Unchanged: %d files\n", len(p.Unchanged))
+	if len(p.Unsafe) > 0 {
+		s += "Unsafe paths in the state, not removed:\n"
+		for _, m := range p.Unsafe {
+			s += fmt.Sprintf("  %s\n", m)
+		}
+	}
+	return s
+}
+
+// Changed reports whether the install would change any file, leaving out
+// the files still waiting to be downloaded manually.
+func (p *Plan) Changed() bool {
+	return len(p.Remove) > 0 || len(p.Dirs) > 0 || len(p.Install) > len(p.Manual)
+}
+
+// Plan computes the changes of Install without making them. A directory
+// without anything installed is left as it is.
+func (i *LocalInstaller) Plan(ctx context.Context) (*Plan, error) {
+	if _, err := os.Stat(CacheDir(i.BaseDir)); err == nil {
+		unlock, err := i.lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	state, err := i.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("check updates: %w", err)
+	}
+	return i.plan(ctx, state)
+}
+
+// plan compares state with the pack, checks the integrity of unchanged files
+// and resolves the conflicts between the paths to remove and to install.
+func (i *LocalInstaller) plan(ctx context.Context, state *State) (*Plan, error) {
+	prevs := make(map[string]*Mod, len(state.Files))
+	for _, m := range state.Files {
+		prevs[m.Path] = m
+	}
+	target := i.targetMods()
+	update := i.getUpdates(state.Files, target)
+	plan := &Plan{
+		target:  target,
+		updated: make(map[*Mod]bool, len(update.Updated)),
+	}
+	for _, m := range update.Updated {
+		plan.updated[m] = true
+	}
+
+	mut := sync.Mutex{}
+	eg, _ := newGroup(ctx)
+	for _, m := range update.Unchanged {
+		eg.Go(func() error {
+			status, err := i.checkIntegrity(m, prevs[m.Path])
+			if err != nil {
+				return fmt.Errorf("check integrity: %w", err)
+			}
+			mut.Lock()
+			defer mut.Unlock()
+			if status == Integrity_Intact {
+				plan.Unchanged = append(plan.Unchanged, m)
+			} else {
+				plan.Damaged = append(plan.Damaged, &DamagedFile{Mod: m, Status: status})
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	plan.Install = slices.Concat(update.Added, update.Updated)
+	plan.damaged = make(map[*Mod]bool, len(plan.Damaged))
+	for _, d := range plan.Damaged {
+		plan.Install = append(plan.Install, d.Mod)
+		plan.damaged[d.Mod] = true
+	}
+	for _, m := range plan.Install {
+		if slices.ContainsFunc(state.Manual, func(s *Mod) bool { return s.Path == m.Path && s.Hash == m.Hash }) {
+			plan.Manual = append(plan.Manual, m)
+		}
+	}
+
+	// on case-insensitive filesystems, paths differing only in case are
+	// the same file
+	key := func(p string) string { return p }
+	if i.caseInsensitive() {
+		key = strings.ToLower
+	}
+
+	files := make(map[string]*Mod, len(target))
+	for _, m := range target {
+		if o, ok := files[key(m.Path)]; ok {
+			return nil, fmt.Errorf("pack has %s and %s at the same path", o.Path, m.Path)
+		}
+		files[key(m.Path)] = m
+	}
+	for _, m := range target {
+		for d := path.Dir(m.Path); d != "."; d = path.Dir(d) {
+			if o, ok := files[key(d)]; ok {
+				return nil, fmt.Errorf("pack has %s as a file and as the directory of %s", o.Path, m.Path)
+			}
+		}
+	}
+
+	installs := make(map[string]bool, len(plan.Install))
+	for _, m := range plan.Install {
+		installs[key(m.Path)] = true
+	}
+	removes := make(map[string]bool, len(update.Removed))
+	for _, m := range update.Removed {
+		// the same file as a kept one of the pack
+		if _, ok := files[key(m.Path)]; ok && !installs[key(m.Path)] {
+			continue
+		}
+		// checked before anything is removed, as a failure in the middle of
+		// removals would fail every later install too
+		if _, err := i.modPath(m); err != nil {
+			var unsafe *UnsafePathError
+			if !errors.As(err, &unsafe) {
+				return nil, err
+			}
+			plan.Unsafe = append(plan.Unsafe, m)
+			continue
+		}
+		plan.Remove = append(plan.Remove, m)
+		removes[key(m.Path)] = true
+	}
+
+	// files and directories in the way of files to install
+	for _, m := range plan.Install {
+		p, err := i.modPath(m)
+		if err != nil {
+			return nil, err
+		}
+		for d := path.Dir(m.Path); d != "."; d = path.Dir(d) {
+			// symlinked directories are followed, as by safeJoin
+			stat, err := os.Stat(filepath.Join(i.BaseDir, filepath.FromSlash(d)))
+			if err != nil || stat.IsDir() {
+				continue
+			}
+			if !removes[key(d)] {
+				return nil, fmt.Errorf("%s is a file in the way of %s, move it away to install the file", d, m.Path)
+			}
+		}
+
+		stat, err := os.Lstat(p)
+		if err != nil || !stat.IsDir() {
+			continue
+		}
+		err = filepath.WalkDir(p, func(sub string, e fs.DirEntry, err error) error {
+			if err != nil || e.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(i.BaseDir, sub)
+			if err != nil {
+				return err
+			}
+			if !removes[key(filepath.ToSlash(rel))] {
+				return fmt.Errorf("%s is a directory with files in it, move it away to install the file", m.Path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		plan.Dirs = append(plan.Dirs, m.Path)
+	}
+	return plan, nil
+}
+
+// caseInsensitive reports whether BaseDir is on a case-insensitive
+// filesystem, by looking up the cache directory in upper case.
+func (i *LocalInstaller) caseInsensitive() bool {
+	dir := CacheDir(i.BaseDir)
+	a, err := os.Stat(dir)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(filepath.Join(i.BaseDir, strings.ToUpper(filepath.Base(dir))))
+	return err == nil && os.SameFile(a, b)
+}
+
+// removeDirTree removes the directory p holding only empty directories,
+// deepest first. It fails if a file is left in it.
+func removeDirTree(p string) error {
+	var dirs []string
+	err := filepath.WalkDir(p, func(sub string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			dirs = append(dirs, sub)
+		}
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	slices.Reverse(dirs)
+	for _, d := range dirs {
+		if err := os.Remove(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
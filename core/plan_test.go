@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalInstaller_InstallPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  map[string]string
+		after   map[string]string
+		extra   map[string]string
+		want    map[string]string
+		gone    []string
+		wantErr bool
+	}{
+		{
+			name:   "renamed into the path of a removed file",
+			before: map[string]string{"mods/a.jar": "A", "mods/b.jar": "B"},
+			after:  map[string]string{"mods/b.jar": "A"},
+			want:   map[string]string{"mods/b.jar": "A"},
+			gone:   []string{"mods/a.jar"},
+		},
+		{
+			name:   "file becomes directory",
+			before: map[string]string{"config/x": "file"},
+			after:  map[string]string{"config/x/y.cfg": "y"},
+			want:   map[string]string{"config/x/y.cfg": "y"},
+		},
+		{
+			name:   "directory becomes file",
+			before: map[string]string{"config/d/z.cfg": "z", "config/d/sub/w.cfg": "w"},
+			after:  map[string]string{"config/d": "file"},
+			want:   map[string]string{"config/d": "file"},
+		},
+		{
+			name:    "user file in the way of directory",
+			before:  map[string]string{"mods/a.jar": "A"},
+			after:   map[string]string{"mods/a.jar": "A2", "config/x/y.cfg": "y"},
+			extra:   map[string]string{"config/x": "mine"},
+			want:    map[string]string{"mods/a.jar": "A", "config/x": "mine"},
+			wantErr: true,
+		},
+		{
+			name:    "user files in the way of file",
+			before:  map[string]string{"config/d/z.cfg": "z"},
+			after:   map[string]string{"config/d": "file"},
+			extra:   map[string]string{"config/d/mine.cfg": "mine"},
+			want:    map[string]string{"config/d/z.cfg": "z", "config/d/mine.cfg": "mine"},
+			wantErr: true,
+		},
+		{
+			name:    "file and directory in the pack",
+			before:  map[string]string{"mods/a.jar": "A"},
+			after:   map[string]string{"config/x": "x", "config/x/y.cfg": "y"},
+			want:    map[string]string{"mods/a.jar": "A"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestPackServer(t, tt.before)
+			dir := t.TempDir()
+			ctx := context.Background()
+			if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+				t.Fatal(err)
+			}
+			for name, body := range tt.extra {
+				writeTestFile(t, filepath.Join(dir, filepath.FromSlash(name)), body)
+			}
+
+			srv.setFiles(tt.after)
+			_, err := newTestInstaller(t, srv, dir).Install(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Install() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+			for _, name := range tt.gone {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("%s is not removed: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalInstaller_Plan(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "A", "mods/b.jar": "B"})
+	dir := t.TempDir()
+	ctx := context.Background()
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.setFiles(map[string]string{"mods/b.jar": "B2", "mods/c.jar": "C"})
+
+	plan, err := newTestInstaller(t, srv, dir).Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0].Path != "mods/a.jar" {
+		t.Errorf("Remove = %v", plan.Remove)
+	}
+	if len(plan.Install) != 2 {
+		t.Errorf("Install = %v", plan.Install)
+	}
+	// nothing is changed
+	if data, _ := os.ReadFile(filepath.Join(dir, "mods", "a.jar")); string(data) != "A" {
+		t.Errorf("a.jar = %q, want %q", data, "A")
+	}
+}
+
+func TestLocalInstaller_InstallSymlinkedDir(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"mods/a.jar": "A"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("shared", filepath.Join(dir, "mods")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "shared", "a.jar")); string(data) != "A" {
+		t.Errorf("shared/a.jar = %q, want %q", data, "A")
+	}
+
+	srv.setFiles(map[string]string{"mods/a.jar": "A2", "mods/b.jar": "B"})
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "shared", "b.jar")); string(data) != "B" {
+		t.Errorf("shared/b.jar = %q, want %q", data, "B")
+	}
+}
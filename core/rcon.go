@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Packet types of the RCON protocol. A command and an auth response share
+// the same type, told apart by direction.
+const (
+	rcon_Response     int32 = 0
+	rcon_Command      int32 = 2
+	rcon_AuthResponse int32 = 2
+	rcon_Auth         int32 = 3
+)
+
+var (
+	rconTimeout = 10 * time.Second
+	// rconMaxPacket is the largest packet accepted, above the 4096 byte body
+	// of Minecraft servers.
+	rconMaxPacket int32 = 1 << 16
+)
+
+var ErrRconAuth = errors.New("rcon: wrong password")
+
+// RconClient sends commands to a game server over RCON.
+type RconClient struct {
+	conn net.Conn
+	id   int32
+}
+
+// DialRcon connects to the RCON server at addr and logs in with password.
+func DialRcon(ctx context.Context, addr string, password string) (*RconClient, error) {
+	d := net.Dialer{Timeout: rconTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &RconClient{conn: conn}
+	if err := c.auth(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *RconClient) auth(password string) error {
+	id, err := c.write(rcon_Auth, password)
+	if err != nil {
+		return err
+	}
+	// Source servers send an empty response before the auth response
+	for {
+		rid, typ, _, err := c.read()
+		if err != nil {
+			return err
+		}
+		if typ != rcon_AuthResponse {
+			continue
+		}
+		if rid == -1 {
+			return ErrRconAuth
+		}
+		if rid != id {
+			return fmt.Errorf("rcon: auth response to request %d, want %d", rid, id)
+		}
+		return nil
+	}
+}
+
+// Command runs cmd on the server and returns its response.
+func (c *RconClient) Command(cmd string) (string, error) {
+	id, err := c.write(rcon_Command, cmd)
+	if err != nil {
+		return "", err
+	}
+	rid, typ, body, err := c.read()
+	if err != nil {
+		return "", err
+	}
+	if typ != rcon_Response || rid != id {
+		return "", fmt.Errorf("rcon: unexpected packet type %d to request %d", typ, rid)
+	}
+	return body, nil
+}
+
+func (c *RconClient) Close() error {
+	return c.conn.Close()
+}
+
+// write sends a packet of typ and returns its id.
+func (c *RconClient) write(typ int32, body string) (int32, error) {
+	c.id++
+	buf := make([]byte, 12, 14+len(body))
+	binary.LittleEndian.PutUint32(buf[0:], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(c.id))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(typ))
+	buf = append(buf, body...)
+	buf = append(buf, 0, 0)
+
+	c.conn.SetDeadline(time.Now().Add(rconTimeout))
+	if _, err := c.conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return c.id, nil
+}
+
+// read receives a packet.
+func (c *RconClient) read() (id int32, typ int32, body string, err error) {
+	c.conn.SetDeadline(time.Now().Add(rconTimeout))
+	var size int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	}
+	if size < 10 || size > rconMaxPacket {
+		return 0, 0, "", fmt.Errorf("rcon: invalid packet size %d", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return 0, 0, "", err
+	}
+	id = int32(binary.LittleEndian.Uint32(buf[0:]))
+	typ = int32(binary.LittleEndian.Uint32(buf[4:]))
+	return id, typ, string(buf[8 : size-2]), nil
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// testRconServer is a fake RCON server with the password "secret". It
+// answers "list" and shuts down on "stop" without replying, as Minecraft
+// servers do.
+type testRconServer struct {
+	net.Listener
+}
+
+func newTestRconServer(t *testing.T) *testRconServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testRconServer{Listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testRconServer) serve(conn net.Conn) {
+	defer conn.Close()
+	write := func(id, typ int32, body string) {
+		buf := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(id))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(typ))
+		conn.Write(append(append(buf, body...), 0, 0))
+	}
+	authed := false
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.LittleEndian, &size); err != nil {
+			return
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		id := int32(binary.LittleEndian.Uint32(buf[0:]))
+		typ := int32(binary.LittleEndian.Uint32(buf[4:]))
+		body := string(buf[8 : size-2])
+
+		switch {
+		case typ == rcon_Auth:
+			write(id, rcon_Response, "")
+			if body != "secret" {
+				id = -1
+			}
+			authed = id != -1
+			write(id, rcon_AuthResponse, "")
+		case typ == rcon_Command && authed:
+			if body == "stop" {
+				s.Close()
+				return
+			}
+			write(id, rcon_Response, "There are 0 of a max of 20 players online: ")
+		default:
+			return
+		}
+	}
+}
+
+func TestRcon(t *testing.T) {
+	s := newTestRconServer(t)
+	addr := s.Addr().String()
+
+	if _, err := DialRcon(context.Background(), addr, "wrong"); !errors.Is(err, ErrRconAuth) {
+		t.Errorf("DialRcon() wrong password error = %v, want ErrRconAuth", err)
+	}
+
+	c, err := DialRcon(context.Background(), addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	res, err := c.Command("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "There are 0 of a max of 20 players online: " {
+		t.Errorf("Command() = %q", res)
+	}
+
+	if _, err := c.Command("stop"); !errors.Is(err, io.EOF) {
+		t.Errorf("Command(stop) error = %v, want EOF", err)
+	}
+	if _, err := DialRcon(context.Background(), addr, "secret"); err == nil {
+		t.Errorf("DialRcon() after stop error = nil")
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type RepairResult struct {
+	Repaired []*DamagedFile
+	Intact   []*Mod
+}
+
+func (r *RepairResult) String() string {
+	var s string
+	s += "Repaired:\n"
+	for _, d := range r.Repaired {
+		s += fmt.Sprintf("  %s\n", d)
+	}
+	s += fmt.Sprintf("Intact: %d files\n", len(r.Intact))
+	return s
+}
+
+// LoadInstalledPack returns the pack installed in dir as recorded in its
+// state, without fetching it. The URL is empty when the state was migrated
+// from installed.json.
+func LoadInstalledPack(dir string) (*Pack, error) {
+	state, err := LoadState(dir)
+	if err != nil {
+		return nil, err
+	}
+	if state.Pack.Url == "" && len(state.Files) == 0 {
+		return nil, fmt.Errorf("no pack is installed in %s", dir)
+	}
+	return &Pack{
+		Url:     state.Pack.Url,
+		Name:    state.Pack.Name,
+		Version: state.Pack.Version,
+		Mods:    state.Files,
+	}, nil
+}
+
+// remotePack loads the current pack from its URL, used when the recorded
+// download of a file is not available anymore.
+func (i *LocalInstaller) remotePack(ctx context.Context) (*Pack, error) {
+	if i.Pack.Url == "" {
+		return nil, fmt.Errorf("the pack URL is not recorded, run install")
+	}
+	u, err := url.Parse(i.Pack.Url)
+	if err != nil {
+		return nil, err
+	}
+	repo := NewRepository(u, "", "")
+	if err := repo.Load(ctx); err != nil {
+		return nil, err
+	}
+	return NewPack(repo)
+}
+
+// Repair rehashes every file in the installed state and downloads the
+// missing or corrupted ones again from their recorded source. If that fails,
+// the file is looked up in the pack fetched from its URL.
+func (i *LocalInstaller) Repair(ctx context.Context) (*RepairResult, error) {
+	var result = &RepairResult{}
+	unlock, err := i.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := i.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("load state: %w", err)
+	}
+	if err := i.removeTempFiles(state.Files); err != nil {
+		return nil, fmt.Errorf("remove temp files: %w", err)
+	}
+
+	var (
+		remote     *Pack
+		remoteErr  error
+		remoteOnce sync.Once
+	)
+	remoteMod := func(m *Mod) (*Mod, error) {
+		remoteOnce.Do(func() {
+			remote, remoteErr = i.remotePack(ctx)
+		})
+		if remoteErr != nil {
+			return nil, fmt.Errorf("load pack: %w", remoteErr)
+		}
+		idx := slices.IndexFunc(remote.Mods, func(r *Mod) bool {
+			return r.Path == m.Path && r.Hash == m.Hash
+		})
+		if idx == -1 {
+			return nil, fmt.Errorf("%s is not in the pack anymore, run install", m.Path)
+		}
+		return remote.Mods[idx], nil
+	}
+
+	mut := sync.Mutex{}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.NumCPU())
+	for _, m := range state.Files {
+		eg.Go(func() error {
+			status, err := i.checkIntegrity(m, nil)
+			if err != nil {
+				return fmt.Errorf("check integrity: %w", err)
+			}
+			if status == Integrity_Intact {
+				mut.Lock()
+				result.Intact = append(result.Intact, m)
+				mut.Unlock()
+				return nil
+			}
+			if err := i.clearDamaged(m, status); err != nil {
+				return fmt.Errorf("repair %s: %w", m.Path, err)
+			}
+
+			err = i.InstallMod(ctx, m)
+			if err != nil {
+				r, rerr := remoteMod(m)
+				if rerr != nil {
+					return fmt.Errorf("repair %s: %w (%w)", m.Path, err, rerr)
+				}
+				m.Downloads = r.Downloads
+				if err := i.InstallMod(ctx, m); err != nil {
+					return fmt.Errorf("repair %s: %w", m.Path, err)
+				}
+			}
+			mut.Lock()
+			result.Repaired = append(result.Repaired, &DamagedFile{Mod: m, Status: status})
+			mut.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	state.Dirs = i.ownedDirs(state.Dirs)
+	if err := state.save(i.BaseDir); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalInstaller_Repair(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{
+		"config/a.txt": "a",
+		"config/b.txt": "b",
+		"config/c.txt": "c",
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config", "a.txt"), []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "config", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	repair := func() *RepairResult {
+		t.Helper()
+		pack, err := LoadInstalledPack(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst, err := NewLocalInstaller(pack, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := inst.Repair(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	srv.requests.Store(0)
+	res := repair()
+	if len(res.Repaired) != 2 || len(res.Intact) != 1 {
+		t.Errorf("Repair() Repaired = %d, Intact = %d, want 2, 1", len(res.Repaired), len(res.Intact))
+	}
+	if got := srv.requests.Load(); got != 2 {
+		t.Errorf("Repair() requests = %d, want 2", got)
+	}
+	for name, want := range map[string]string{"a.txt": "a", "b.txt": "b"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "config", name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+
+	// recorded source is gone
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range state.Files {
+		m.Downloads.Data = srv.URL + "/gone/" + m.Path
+	}
+	if err := state.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "config", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	res = repair()
+	if len(res.Repaired) != 1 || res.Repaired[0].Mod.Path != "config/c.txt" || res.Repaired[0].Status != Integrity_Missing {
+		t.Errorf("Repair() fallback Repaired = %v", res.Repaired)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config", "c.txt")); string(data) != "c" {
+		t.Errorf("c.txt = %q, want %q", data, "c")
+	}
+}
+
+func TestLocalInstaller_RepairLegacyState(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"config/a.txt": "a", "config/b.txt": "b"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// installed.json only has the files, without the pack
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveJsonFile(legacyStatePath(dir), state.Files); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(statePath(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "config", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := LoadInstalledPack(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inst.Repair(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Repaired) != 1 || len(res.Intact) != 1 {
+		t.Errorf("Repair() Repaired = %d, Intact = %d, want 1, 1", len(res.Repaired), len(res.Intact))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config", "a.txt")); string(data) != "a" {
+		t.Errorf("a.txt = %q, want %q", data, "a")
+	}
+
+	// without files, nothing is installed
+	if _, err := LoadInstalledPack(t.TempDir()); err == nil {
+		t.Errorf("LoadInstalledPack() of an empty directory error = nil")
+	}
+}
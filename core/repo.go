@@ -2,15 +2,29 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
 type RepoOptFn func(r *Repository)
 
+// RepoCache is the state of a previous Load, used to skip refetching
+// unchanged pack metadata.
+type RepoCache struct {
+	Url        string          `json:"url"`
+	Validators httpValidators  `json:"validators"`
+	PackData   []byte          `json:"pack"`
+	Index      *IndexToml      `json:"index"`
+	Metafiles  []*MetafileToml `json:"metafiles"`
+}
+
 type Repository struct {
 	Url            *url.URL
 	Pack           *PackToml
@@ -18,33 +32,125 @@ type Repository struct {
 	Metafiles      []*MetafileToml
 	PackHashFormat string
 	PackHash       string
-	httpClient     *http.Client
+	// FromCache reports whether the index and metafiles of the last Load
+	// were reused from the cache.
+	FromCache    bool
+	cacheDir     string
+	readOnly     bool
+	lockTimeout  time.Duration
+	ignoreFormat bool
+	cache        *RepoCache
+	packData     []byte
+	validators   httpValidators
+	httpClient   *http.Client
 }
 
-func NewRepository(url *url.URL, hashFormat, hash string) *Repository {
-	return &Repository{
+func NewRepository(url *url.URL, hashFormat, hash string, opts ...RepoOptFn) *Repository {
+	r := &Repository{
 		Url:            url,
 		PackHashFormat: hashFormat,
 		PackHash:       hash,
 		httpClient:     http.DefaultClient,
+		lockTimeout:    time.Minute,
+	}
+	for _, fn := range opts {
+		fn(r)
+	}
+	return r
+}
+
+// WithCacheDir makes Load keep conditional request validators and parsed
+// metadata in dir between runs.
+func WithCacheDir(dir string) RepoOptFn {
+	return func(r *Repository) {
+		r.cacheDir = dir
+	}
+}
+
+// WithReadOnlyCache makes Load use the cache without writing it or creating
+// its dir, for dry runs.
+func WithReadOnlyCache(readOnly bool) RepoOptFn {
+	return func(r *Repository) {
+		r.readOnly = readOnly
 	}
 }
 
+// WithLockTimeout sets how long Load waits for an install into the directory
+// of the cache dir to finish.
+func WithLockTimeout(d time.Duration) RepoOptFn {
+	return func(r *Repository) {
+		r.lockTimeout = d
+	}
+}
+
+// WithHttpClient makes Load fetch the pack with c.
+func WithHttpClient(c *http.Client) RepoOptFn {
+	return func(r *Repository) {
+		r.httpClient = c
+	}
+}
+
+// WithIgnoreFormat makes Load accept packs of unsupported pack-format versions.
+func WithIgnoreFormat(ignore bool) RepoOptFn {
+	return func(r *Repository) {
+		r.ignoreFormat = ignore
+	}
+}
+
+func (r *Repository) cachePath() string {
+	return filepath.Join(r.cacheDir, "repository.json")
+}
+
+func (r *Repository) restoreCache() error {
+	if r.cacheDir == "" {
+		return nil
+	}
+	var c *RepoCache
+	if err := restoreJsonFile(r.cachePath(), &c); err != nil {
+		return err
+	}
+	if c == nil || c.Url != r.Url.String() || c.PackData == nil || c.Index == nil {
+		return nil
+	}
+	r.cache = c
+	return nil
+}
+
+func (r *Repository) saveCache() error {
+	if r.cacheDir == "" || r.readOnly {
+		return nil
+	}
+	r.cache = &RepoCache{
+		Url:        r.Url.String(),
+		Validators: r.validators,
+		PackData:   r.packData,
+		Index:      r.Index,
+		Metafiles:  r.Metafiles,
+	}
+	return saveJsonFile(r.cachePath(), r.cache)
+}
+
 func (r *Repository) loadPack(ctx context.Context) (*PackToml, error) {
-	var (
-		data []byte
-		err  error
-	)
+	var prev httpValidators
+	if r.cache != nil {
+		prev = r.cache.Validators
+	}
 
-	if r.PackHash == "" {
-		data, err = httpGetBytes(ctx, r.httpClient, r.Url.String())
+	data, next, modified, err := httpGetConditionalBytes(ctx, r.httpClient, r.Url.String(), prev)
+	if err != nil {
+		return nil, err
+	}
+	if !modified {
+		data = r.cache.PackData
+	}
+
+	if r.PackHash != "" {
+		valid, err := MatchHash(data, r.PackHashFormat, r.PackHash)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		data, err = httpGetValidBytes(ctx, r.httpClient, r.Url.String(), r.PackHashFormat, r.PackHash)
-		if err != nil {
-			return nil, err
+		if !valid {
+			return nil, fmt.Errorf("download hash mismatched: %s", r.Url)
 		}
 	}
 
@@ -52,7 +158,17 @@ func (r *Repository) loadPack(ctx context.Context) (*PackToml, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !r.ignoreFormat {
+		if err := checkPackFormat(pack.PackFormat); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkHashFormat(pack.Index.HashFormat, "pack.toml"); err != nil {
+		return nil, err
+	}
 	r.Pack = pack
+	r.packData = data
+	r.validators = next
 	return pack, nil
 }
 
@@ -79,6 +195,9 @@ func (r *Repository) loadIndex(ctx context.Context) (*IndexToml, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkIndexHashFormats(index); err != nil {
+		return nil, err
+	}
 	r.Index = index
 	return index, nil
 }
@@ -132,11 +251,57 @@ func (r *Repository) loadMetafiles(ctx context.Context) ([]*MetafileToml, error)
 	return mods, nil
 }
 
+// indexUnchanged reports whether the loaded pack points at the same index
+// as the cached one.
+func (r *Repository) indexUnchanged() bool {
+	if r.cache == nil {
+		return false
+	}
+	cached, err := parsePackToml(r.cache.PackData)
+	if err != nil {
+		return false
+	}
+	return cached.Index == r.Pack.Index
+}
+
 func (r *Repository) Load(ctx context.Context) error {
-	_, err := r.loadMetafiles(ctx)
+	r.FromCache = false
+	// the cache is shared with installs into the same directory, and
+	// written atomically, so it is read without the lock when read-only
+	if r.cacheDir != "" && !r.readOnly {
+		if err := os.MkdirAll(r.cacheDir, dirPerm); err != nil {
+			return err
+		}
+		release, err := acquireLock(ctx, cacheLockPath(r.cacheDir), r.lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	if err := r.restoreCache(); err != nil {
+		return fmt.Errorf("restore repository cache: %w", err)
+	}
+
+	_, err := r.loadPack(ctx)
 	if err != nil {
 		return err
 	}
+
+	if r.indexUnchanged() {
+		r.Index = r.cache.Index
+		r.Metafiles = r.cache.Metafiles
+		r.FromCache = true
+	} else {
+		r.Index = nil
+		_, err = r.loadMetafiles(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := r.saveCache(); err != nil {
+		return fmt.Errorf("save repository cache: %w", err)
+	}
 	return nil
 }
 
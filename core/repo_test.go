@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+type testPackServer struct {
+	*httptest.Server
+	files    map[string]string
+	requests atomic.Int32
+	// slow is a file which is sent partially and then stalls until the
+	// request is canceled.
+	slow atomic.Pointer[string]
+}
+
+// newTestPackServer serves files and a generated pack.toml and index.toml
+// referencing them. Files ending in ".pw.toml" are marked as metafiles.
+func newTestPackServer(t *testing.T, files map[string]string) *testPackServer {
+	t.Helper()
+	s := &testPackServer{}
+	s.setFiles(files)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		body, ok := s.files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if slow := s.slow.Load(); slow != nil && *slow == name {
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			fmt.Fprint(w, body[:len(body)/2])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		etag := `"` + sha256Hex(body) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// setFiles replaces the served files.
+func (s *testPackServer) setFiles(files map[string]string) {
+	s.files = map[string]string{}
+	var index strings.Builder
+	index.WriteString("hash-format = \"sha256\"\n")
+	for name, body := range files {
+		s.files[name] = body
+		fmt.Fprintf(&index, "\n[[files]]\nfile = %q\nhash = %q\n", name, sha256Hex(body))
+		if strings.HasSuffix(name, ".pw.toml") {
+			index.WriteString("metafile = true\n")
+		}
+	}
+	s.files["index.toml"] = index.String()
+	s.files["pack.toml"] = fmt.Sprintf(`name = "test"
+pack-format = "packwiz:1.1.0"
+
+[index]
+file = "index.toml"
+hash-format = "sha256"
+hash = %q
+`, sha256Hex(index.String()))
+}
+
+func (s *testPackServer) packUrl(t *testing.T) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s.URL + "/pack.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRepository_LoadCached(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{
+		"config/a.txt": "a",
+		"mods/b.pw.toml": `name = "B"
+filename = "b.jar"
+[download]
+url = "https://example.com/b.jar"
+hash-format = "sha256"
+hash = "00"
+`,
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo := NewRepository(srv.packUrl(t), "", "", WithCacheDir(dir))
+	if err := repo.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if repo.FromCache {
+		t.Errorf("first Load() FromCache = true")
+	}
+	if got := srv.requests.Load(); got != 3 {
+		t.Errorf("first Load() requests = %d, want 3", got)
+	}
+
+	srv.requests.Store(0)
+	repo = NewRepository(srv.packUrl(t), "", "", WithCacheDir(dir))
+	if err := repo.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.FromCache {
+		t.Errorf("second Load() FromCache = false")
+	}
+	if got := srv.requests.Load(); got != 1 {
+		t.Errorf("second Load() requests = %d, want 1", got)
+	}
+	if len(repo.Metafiles) != 1 || repo.Metafiles[0].IndexName != "mods/b.pw.toml" {
+		t.Errorf("second Load() Metafiles = %v", repo.Metafiles)
+	}
+
+	srv.setFiles(map[string]string{"config/a.txt": "changed"})
+	srv.requests.Store(0)
+	repo = NewRepository(srv.packUrl(t), "", "", WithCacheDir(dir))
+	if err := repo.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if repo.FromCache {
+		t.Errorf("Load() after change FromCache = true")
+	}
+	if got := srv.requests.Load(); got != 2 {
+		t.Errorf("Load() after change requests = %d, want 2", got)
+	}
+}
+
+func TestRepository_LoadFileUrl(t *testing.T) {
+	dir := t.TempDir()
+	s := &testPackServer{}
+	s.setFiles(map[string]string{"config/a.txt": "a"})
+	for name, body := range s.files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := FileUrl(filepath.Join(dir, "pack.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only local packs read file:// URLs
+	if err := NewRepository(u, "", "").Load(context.Background()); err == nil {
+		t.Errorf("Load() with the default client error = nil")
+	}
+	repo := NewRepository(u, "", "", WithHttpClient(NewLocalFileClient()))
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Index.Files) != 1 {
+		t.Errorf("Load() index files = %v", repo.Index.Files)
+	}
+}
+
+func TestRepository_LoadReadOnlyCache(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{"config/a.txt": "a"})
+	dir := filepath.Join(t.TempDir(), "instance")
+	ctx := context.Background()
+
+	repo := NewRepository(srv.packUrl(t), "", "", WithCacheDir(CacheDir(dir)), WithReadOnlyCache(true))
+	if err := repo.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := inst.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Install) != 1 {
+		t.Errorf("Plan() Install = %v", plan.Install)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("dry run created the install directory: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const stateVersion = 1
+
+// PackIdentity identifies the pack a directory was installed from.
+type PackIdentity struct {
+	Url     string `json:"url"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Same reports whether p and o are the same pack, ignoring its version.
+func (p PackIdentity) Same(o PackIdentity) bool {
+	return p.Url == o.Url && p.Name == o.Name
+}
+
+func (p PackIdentity) String() string {
+	if p.Version == "" {
+		return fmt.Sprintf("%q (%s)", p.Name, p.Url)
+	}
+	return fmt.Sprintf("%q %s (%s)", p.Name, p.Version, p.Url)
+}
+
+// State is the installed state of a directory, stored in .pw-install/state.json.
+type State struct {
+	Version     int             `json:"version"`
+	Pack        PackIdentity    `json:"pack"`
+	InstalledAt time.Time       `json:"installedAt"`
+	Side        Side            `json:"side,omitempty"`
+	Options     map[string]bool `json:"options,omitempty"`
+	Files       []*Mod          `json:"files"`
+	// Dirs are the directories created by installs, which are pruned when
+	// they become empty.
+	Dirs []string `json:"dirs,omitempty"`
+	// Manual are the files which had to be downloaded manually and are not
+	// installed yet.
+	Manual []*Mod `json:"manual,omitempty"`
+}
+
+type PackMismatchError struct {
+	Installed PackIdentity
+	Pack      PackIdentity
+}
+
+func (e *PackMismatchError) Error() string {
+	return fmt.Sprintf("directory already has pack %s installed, not %s (use --force to replace it)", e.Installed, e.Pack)
+}
+
+func statePath(baseDir string) string {
+	return filepath.Join(CacheDir(baseDir), "state.json")
+}
+
+func legacyStatePath(baseDir string) string {
+	return filepath.Join(CacheDir(baseDir), "installed.json")
+}
+
+// LoadState reads the installed state of baseDir, migrating the legacy
+// installed.json if needed. A directory without state yields an empty State.
+func LoadState(baseDir string) (*State, error) {
+	var s *State
+	if err := restoreJsonFile(statePath(baseDir), &s); err != nil {
+		return nil, err
+	}
+	if s != nil {
+		if s.Version > stateVersion {
+			return nil, fmt.Errorf("unsupported state version %d, update packwiz-install", s.Version)
+		}
+		return s, nil
+	}
+
+	// installed.json only holds the list of files
+	var mods []*Mod
+	if err := restoreJsonFile(legacyStatePath(baseDir), &mods); err != nil {
+		return nil, err
+	}
+	return &State{Version: stateVersion, Files: mods}, nil
+}
+
+func (s *State) save(baseDir string) error {
+	s.Version = stateVersion
+	if err := saveJsonFile(statePath(baseDir), s); err != nil {
+		return err
+	}
+	err := os.Remove(legacyStatePath(baseDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
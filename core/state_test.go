@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadState_Legacy(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `[{"path": "mods/a.jar", "hash": "00", "hashFormat": "sha1", "download": {"type": "url", "data": "https://example.com/a.jar"}}]`
+	if err := os.MkdirAll(CacheDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyStatePath(dir), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != stateVersion || len(s.Files) != 1 || s.Files[0].Path != "mods/a.jar" {
+		t.Fatalf("LoadState() = %+v", s)
+	}
+
+	if err := s.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacyStatePath(dir)); !os.IsNotExist(err) {
+		t.Errorf("installed.json still exists after save")
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir(dir), "state.json")); err != nil {
+		t.Errorf("state.json: %v", err)
+	}
+}
+
+func TestLoadState_Empty(t *testing.T) {
+	s, err := LoadState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 0 || s.Pack.Url != "" {
+		t.Errorf("LoadState() = %+v, want empty", s)
+	}
+}
+
+func TestLocalInstaller_InstallOtherPack(t *testing.T) {
+	srvA := newTestPackServer(t, map[string]string{"config/a.txt": "a"})
+	srvB := newTestPackServer(t, map[string]string{"config/b.txt": "b"})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srvA, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := newTestInstaller(t, srvB, dir).Install(ctx)
+	var mismatch *PackMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Install() other pack error = %v, want PackMismatchError", err)
+	}
+
+	inst := newTestInstaller(t, srvB, dir)
+	inst.Force = true
+	res, err := inst.Install(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Added) != 1 || len(res.Removed) != 1 {
+		t.Errorf("Install() forced: Added = %d, Removed = %d, want 1, 1", len(res.Added), len(res.Removed))
+	}
+	s, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Pack.Url != srvB.packUrl(t).String() {
+		t.Errorf("state pack url = %s, want %s", s.Pack.Url, srvB.packUrl(t))
+	}
+}
@@ -14,6 +14,15 @@ type PackToml struct {
 		Hash       string `toml:"hash"`
 	} `toml:"index"`
 	Versions map[string]string `toml:"versions"`
+	Options  PackOptionsToml   `toml:"options,omitempty"`
+}
+
+// PackOptionsToml holds the extensions of packwiz-install in the options
+// table of pack.toml, which packwiz keeps as is.
+type PackOptionsToml struct {
+	// Executable are path.Match patterns of files installed as executable,
+	// e.g. server start scripts.
+	Executable []string `toml:"packwiz-install-executable,omitempty"`
 }
 
 type IndexToml struct {
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type UninstallResult struct {
+	Removed []*Mod
+	// Dirs are the pruned directories relative to the base directory.
+	Dirs []string
+}
+
+func (u *UninstallResult) String() string {
+	var s string
+	s += "Removed:\n"
+	for _, m := range u.Removed {
+		s += fmt.Sprintf("  %s\n", m)
+	}
+	s += "Pruned directories:\n"
+	for _, d := range u.Dirs {
+		s += fmt.Sprintf("  %s/\n", d)
+	}
+	return s
+}
+
+// Uninstaller removes the files of the pack installed into BaseDir.
+type Uninstaller struct {
+	BaseDir string
+	// DryRun reports what would be removed without removing anything.
+	DryRun      bool
+	LockTimeout time.Duration
+}
+
+func NewUninstaller(dir string) (*Uninstaller, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Uninstaller{
+		BaseDir:     abs,
+		LockTimeout: time.Minute,
+	}, nil
+}
+
+// Uninstall removes every file tracked in the installed state, prunes the
+// directories created by installs which are left empty and removes the state. Untracked files are kept.
+func (u *Uninstaller) Uninstall(ctx context.Context) (*UninstallResult, error) {
+	if _, err := os.Stat(CacheDir(u.BaseDir)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no pack is installed in %s", u.BaseDir)
+		}
+		return nil, err
+	}
+	release, err := acquireLock(ctx, lockPath(u.BaseDir), u.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// the lock is gone with the state dir unless dry run
+	defer release()
+
+	state, err := LoadState(u.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("load state: %w", err)
+	}
+
+	var (
+		result  = &UninstallResult{}
+		removed = map[string]bool{}
+		dirs    []string
+	)
+	for _, m := range state.Files {
+		p, err := safeJoin(u.BaseDir, m.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(p); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !u.DryRun {
+			if err := os.Remove(p); err != nil {
+				return nil, fmt.Errorf("remove mod: %w", err)
+			}
+		}
+		removed[p] = true
+		dirs = append(dirs, filepath.Dir(p))
+		result.Removed = append(result.Removed, m)
+	}
+
+	// the state dir goes last, so it is not pruned with the rest
+	removed[CacheDir(u.BaseDir)] = true
+	// only the directories created by installs, user directories are kept
+	pruned, err := pruneDirs(u.BaseDir, dirs, removed, u.DryRun, ownedDirSet(u.BaseDir, state.Dirs))
+	if err != nil {
+		return nil, fmt.Errorf("prune directories: %w", err)
+	}
+	for _, d := range pruned {
+		rel, err := filepath.Rel(u.BaseDir, d)
+		if err != nil {
+			return nil, err
+		}
+		result.Dirs = append(result.Dirs, filepath.ToSlash(rel))
+	}
+
+	if !u.DryRun {
+		if err := os.RemoveAll(CacheDir(u.BaseDir)); err != nil {
+			return nil, fmt.Errorf("remove state: %w", err)
+		}
+	}
+	return result, nil
+}
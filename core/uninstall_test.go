@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUninstaller_Uninstall(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{
+		"config/sub/a.txt": "a",
+		"config/b.txt":     "b",
+		"mods/c.txt":       "c",
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	user := filepath.Join(dir, "config", "user.txt")
+	if err := os.WriteFile(user, []byte("user"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := NewUninstaller(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.DryRun = true
+	dry, err := u.Uninstall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mods", "c.txt")); err != nil {
+		t.Errorf("dry run removed file: %v", err)
+	}
+
+	u.DryRun = false
+	res, err := u.Uninstall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 3 || len(dry.Removed) != 3 {
+		t.Errorf("Removed = %d, dry run %d, want 3", len(res.Removed), len(dry.Removed))
+	}
+	wantDirs := []string{"config/sub", "mods"}
+	slices.Sort(res.Dirs)
+	slices.Sort(dry.Dirs)
+	if !slices.Equal(res.Dirs, wantDirs) || !slices.Equal(dry.Dirs, wantDirs) {
+		t.Errorf("Dirs = %v, dry run %v, want %v", res.Dirs, dry.Dirs, wantDirs)
+	}
+
+	for _, p := range []string{"config/sub", "config/b.txt", "mods", ".pw-install"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); !os.IsNotExist(err) {
+			t.Errorf("%s remains after uninstall", p)
+		}
+	}
+	if _, err := os.Stat(user); err != nil {
+		t.Errorf("untracked file removed: %v", err)
+	}
+}
+
+func TestUninstaller_KeepsUserDirs(t *testing.T) {
+	srv := newTestPackServer(t, map[string]string{
+		"config/a.cfg":     "a",
+		"config/new/b.cfg": "b",
+	})
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// made by the user before the install
+	if err := os.Mkdir(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestInstaller(t, srv, dir).Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := NewUninstaller(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := u.Uninstall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"config/new"}; !slices.Equal(res.Dirs, want) {
+		t.Errorf("Dirs = %v, want %v", res.Dirs, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config")); err != nil {
+		t.Errorf("user directory removed: %v", err)
+	}
+}